@@ -2,12 +2,15 @@ package bootseq
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -41,22 +44,34 @@ const (
 	phaseDown
 )
 
+// Errors reported when executing a malformed Instance. They fail the boot
+// sequence gracefully rather than crashing the process.
+var (
+	// ErrUnknownPhase is reported when a service is executed in a phase other
+	// than phaseUp or phaseDown.
+	ErrUnknownPhase = errors.New("unknown phase: must match phaseUp or phaseDown")
+
+	// ErrUnknownMode is reported when a sequence of steps is neither serial nor
+	// parallel.
+	ErrUnknownMode = errors.New("unknown mode: failed to boot sequence in serial or parallel mode")
+)
+
 var (
 	// errStepFailure is for error comparisons during testing.
 	errStepFailure = errors.New("step has failed")
 
-	// panicServiceLimit triggers when client attempts to add step 65536 to the manager.
-	panicServiceLimit = "reached limit of max 65535 services"
+	// panicServiceLimit triggers when client attempts to add more services to the
+	// manager than its service limit allows. It's a format string for the limit.
+	panicServiceLimit = "reached limit of max %d services"
 
 	// panicStepLimit triggers when client attempts to add step 256 to any sequence.
 	panicStepLimit = "reached limit of max 255 steps per sequence"
 
-	// panicUnknownPhase triggers when calling service.byPhase() with incorrect phase.
+	// panicUnknownPhase triggers when calling sequence.first() or sequence.next()
+	// with incorrect phase. This should only happen if there's an internal
+	// library error, as agents only ever run in phaseUp or phaseDown.
 	panicUnknownPhase = "unknown phase: must match phaseUp or phaseDown"
 
-	// panicUnknownMode should only trigger if there's an internal library error.
-	panicUnknownMode = "unknown mode: failed to boot sequence in serial or parallel mode"
-
 	// panicCallee triggers if client calls both Agent.Wait() and Agent.Progress().
 	panicCallee = "invalid callee: you may call Agent.Wait() or Agent.Progress(), not both"
 
@@ -66,6 +81,13 @@ var (
 	// panicDown triggers if client calls Agent.Down() twice.
 	panicDown = "call to Agent.Down() on agent which is already a shutdown sequence"
 
+	// panicStarted triggers if client calls Agent.Start() on an agent that has already been started.
+	panicStarted = "call to Agent.Start() on agent which has already been started"
+
+	// panicCompose triggers if client calls Instance.Then() or Instance.With() with an instance that references
+	// services which aren't registered with the manager of the receiver.
+	panicCompose = "cannot compose instances of incompatible managers"
+
 	// Various defaults and texts.
 	parseErrMsg = "parse error"
 )
@@ -75,15 +97,44 @@ var (
 // service must satisfy this type.
 type Func func() error
 
+// ParseErrorKind identifies the kind of problem reported by an
+// ErrParsingFormula, which allows clients to present parse errors in their own
+// words, ie. in another language, rather than matching on the error message.
+type ParseErrorKind uint8
+
+// Kinds of parse errors.
+const (
+	EmptySequence    ParseErrorKind = iota // The formula is empty.
+	UnknownService                         // A service name isn't registered with the manager.
+	UnmatchedParen                         // A parenthesis isn't matched by its counterpart.
+	UnmatchedBrace                         // An opening brace isn't followed by a closing one.
+	DanglingOperator                       // An operator is missing a service or a group on either side.
+	InvalidChar                            // A service name contains an invalid character.
+	WhitespaceInName                       // A service name contains whitespace.
+	MisplacedLimit                         // A concurrency limit doesn't follow a group.
+	InvalidLimit                           // A concurrency limit isn't a positive number.
+	MisplacedTimeout                       // A timeout doesn't end a service name.
+	InvalidTimeout                         // A timeout isn't a positive duration.
+	NestingTooDeep                         // Groups are nested deeper than the parser allows.
+	AliasCollision                         // An alias has since been registered as a service.
+	InvalidNode                            // A node of a JSON formula is neither a service nor a group.
+	UnknownMode                            // A group of a JSON formula has a mode other than serial or parallel.
+)
+
 // ErrParsingFormula represents a parse problem with the formula to the
-// Sequence() method.
+// Sequence() method. Kind tells which problem it is, Token holds the offending
+// service name, character or argument, if any, and Pos holds the position of
+// the problem in the formula, counted in runes, or -1 if it has no position.
 type ErrParsingFormula struct {
+	Kind             ParseErrorKind
+	Token            string
+	Pos              int
 	message, details string
 }
 
 // newParseError is a convenience function for creating a new ErrParsingFormula.
-func newParseError(details string) ErrParsingFormula {
-	err := ErrParsingFormula{parseErrMsg, details}
+func newParseError(kind ParseErrorKind, token string, pos int, details string) ErrParsingFormula {
+	err := ErrParsingFormula{kind, token, pos, parseErrMsg, details}
 	return err
 }
 
@@ -93,13 +144,83 @@ func (e ErrParsingFormula) Error() string {
 	return fmt.Sprintf("%s: %s", e.message, e.details)
 }
 
+// CalleeError is returned by TryWait and TryProgress when the agent has already
+// been waited on, by any of Wait, TryWait, Progress or TryProgress.
+type CalleeError string
+
+// Error satisfies the error interface by returning the error message.
+func (e CalleeError) Error() string {
+	return string(e)
+}
+
+// NilFuncError is returned by Instance.Validate when a service in the sequence
+// has a nil up or down function. It holds the name of the service.
+type NilFuncError string
+
+// Error satisfies the error interface by returning the error message.
+func (e NilFuncError) Error() string {
+	return fmt.Sprintf("nil Func provided: %q", string(e))
+}
+
+// joinedError holds the errors of several failed steps, such as those returned
+// by a best-effort shutdown sequence; see WithBestEffort. errors.Is and
+// errors.As inspect every one of them.
+type joinedError []error
+
+// join returns nil if errs is empty, the only error if it holds a single one,
+// or a joinedError holding all of them otherwise.
+func join(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return joinedError(errs)
+}
+
+// Error satisfies the error interface by returning the error messages, one per
+// line.
+func (e joinedError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is returns true if any of the errors matches target.
+func (e joinedError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, and if so, sets target
+// to that error and returns true.
+func (e joinedError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // A step comprises a sequential slice of sub-steps and a service name which
 // acts as a reference to a service in the Manager.srvcs slice.
 // Finally, a pointer in each direction to the previous/next step.
+// A limit greater than zero caps the number of parallel sub-steps that may
+// execute concurrently.
 type step struct {
 	srvc               string
 	next, prev, parent *step
 	seq                sequence
+	limit              int
+	timeout            time.Duration
 }
 
 // newStep creates and returns a new step for the service with the given name,
@@ -107,7 +228,7 @@ type step struct {
 func newStep(name string) step {
 	seq := sequence{}
 	seq.mode = serial
-	st := step{name, nil, nil, nil, seq}
+	st := step{srvc: name, seq: seq}
 	seq.parent = &st
 	return st
 }
@@ -137,10 +258,11 @@ func (s *step) append(st step) {
 
 // String draws the sequence diagram from the step and all its sub-steps.
 // The returned diagram will always be wrapped in parentheses. No whitespace
-// is present in the diagram.
+// is present in the diagram. Concurrency limits follow their group in braces.
 // Ex: "(aaa:(bbb>ccc))"
 // Ex: "(aaa>bbb>ccc)"
 // Ex: "(aaa)"
+// Ex: "(aaa>(bbb:ccc:ddd){2})"
 func (s step) String() string {
 	var out string
 
@@ -158,12 +280,18 @@ func (s step) String() string {
 	if out == "" {
 		out = s.srvc
 	}
+	if s.timeout > 0 {
+		out += "{" + s.timeout.String() + "}"
+	}
 
 	// Outer parens.
 	prefix, suffix := "", ""
 	if (s.parent == nil && s.srvc != "") || (s.srvc == "" && s.seq.count > 1) {
 		prefix, suffix = "(", ")"
 	}
+	if s.limit > 0 {
+		suffix += "{" + strconv.Itoa(s.limit) + "}"
+	}
 
 	return prefix + out + suffix
 }
@@ -188,6 +316,58 @@ func (s step) Names() []string {
 	return names
 }
 
+// remove unlinks the given step from the sequence of the current step,
+// updating pointers as necessary.
+func (s *step) remove(st *step) {
+	if st.prev == nil {
+		s.seq.head = st.next
+	} else {
+		st.prev.next = st.next
+	}
+	if st.next == nil {
+		s.seq.tail = st.prev
+	} else {
+		st.next.prev = st.prev
+	}
+	st.next, st.prev = nil, nil
+	s.seq.count--
+}
+
+// dedupe removes every step from the sequence of the current step, and the
+// sequences of its nested steps, that refers to a service that has already
+// been seen, keeping the earliest occurrence. Nested steps that are left
+// without any sub-steps are removed as well.
+func (s *step) dedupe(seen map[string]bool) {
+	for curr := s.seq.head; curr != nil; {
+		next := curr.next
+		if curr.seq.count == 0 {
+			if seen[curr.srvc] {
+				s.remove(curr)
+			}
+			seen[curr.srvc] = true
+		} else {
+			curr.dedupe(seen)
+			if curr.seq.count == 0 {
+				s.remove(curr)
+			}
+		}
+		curr = next
+	}
+}
+
+// clone returns a deep copy of the step and all of its sub-steps, so that the
+// copy can be executed independently of the original.
+func (s step) clone() step {
+	c := newStep(s.srvc)
+	c.seq.mode = s.seq.mode
+	c.limit = s.limit
+	c.timeout = s.timeout
+	for curr := s.seq.head; curr != nil; curr = curr.next {
+		c.append(curr.clone())
+	}
+	return c
+}
+
 // sequence represents a sequence of steps, with the added property that it's
 // able to keep track of the head and tail of the chain, as well as the current
 // position during traversal. The empty value is immediately usable.
@@ -233,15 +413,15 @@ type service struct {
 }
 
 // byPhase returns the service function that matches the provided phase.
-// It panics if the phase is unknown.
-func (s service) byPhase(ph phase) Func {
+// It returns ErrUnknownPhase if the phase is unknown.
+func (s service) byPhase(ph phase) (Func, error) {
 	switch ph {
 	case phaseUp:
-		return s.up
+		return s.up, nil
 	case phaseDown:
-		return s.down
+		return s.down, nil
 	default:
-		panic(panicUnknownPhase)
+		return nil, ErrUnknownPhase
 	}
 }
 
@@ -254,32 +434,110 @@ type Progress struct {
 	Err     error
 }
 
+// Runner runs service functions concurrently and waits for them to finish.
+// Wait returns the first non-nil error returned by any of the functions passed
+// to Go. *errgroup.Group satisfies this interface and is used by default.
+type Runner interface {
+	Go(f func() error)
+	Wait() error
+}
+
+// newErrgroupRunner returns the default Runner, backed by an errgroup.
+func newErrgroupRunner() Runner {
+	return &errgroup.Group{}
+}
+
+// limiter is implemented by Runners that support limiting the number of
+// functions that run concurrently, such as *errgroup.Group. Concurrency limits
+// in formulas are ignored for Runners that don't implement it.
+type limiter interface {
+	SetLimit(n int)
+}
+
+// maxServices is the number of services that a Manager can contain at most.
+const maxServices = 65535
+
 // Manager represents a single boot sequence with its own name.
 // Actual up/down functions are stored (and referenced) by name in the map
 // services.
 type Manager struct {
-	Name  string
-	srvcs map[string]service
+	Name      string
+	srvcs     map[string]service
+	newRunner func() Runner
+	aliases   map[string]string // Canonical service names by alias.
+	limit     int               // Max. number of services; see WithServiceLimit.
+	dedupe    bool              // Run each service at most once; see WithDedupeSteps.
 }
 
 // New returns a new and uninitialised boot sequence manager.
 func New(name string) Manager {
 	srvcs := make(map[string]service)
-	s := Manager{name, srvcs}
+	aliases := make(map[string]string)
+	s := Manager{name, srvcs, newErrgroupRunner, aliases, maxServices, false}
 	return s
 }
 
+// WithRunner returns a copy of the Manager that uses the given function to
+// create a new Runner for every step, or group of parallel steps, that it
+// executes. This allows for integration with worker pools or deterministic
+// runners during testing.
+func (m Manager) WithRunner(fn func() Runner) Manager {
+	m.newRunner = fn
+	return m
+}
+
+// WithServiceLimit returns a copy of the Manager that allows no more than n
+// services to be added, which is useful as a guardrail against runaway
+// registrations. Limits outside of the range 1-65535 are replaced by 65535,
+// which is the default.
+func (m Manager) WithServiceLimit(n int) Manager {
+	if n < 1 || n > maxServices {
+		n = maxServices
+	}
+	m.limit = n
+	return m
+}
+
+// WithDedupeSteps returns a copy of the Manager that runs each service at most
+// once per sequence, as a safeguard for services that aren't idempotent. When
+// a formula refers to the same service more than once, Sequence keeps the
+// earliest occurrence and removes the others. This also applies to parallel
+// groups, so "(two:two:three)" runs two and three concurrently, and groups that
+// are left empty are removed altogether.
+func (m Manager) WithDedupeSteps() Manager {
+	m.dedupe = true
+	return m
+}
+
 // Add adds a single named service to the boot sequence, with the given "up" and
 // "down" functions. If a service with the given name already exists, the provided
 // up- and down functions replace those already registered.
 func (m Manager) Add(name string, up, down Func) {
-	if len(m.srvcs) == 65535 {
-		panic(panicServiceLimit)
+	if _, ok := m.srvcs[name]; !ok && len(m.srvcs) >= m.limit {
+		panic(fmt.Sprintf(panicServiceLimit, m.limit))
 	}
 
 	m.srvcs[name] = service{up, down}
 }
 
+// Alias registers a short name that may be used in formulas in place of the
+// canonical name of a registered service. Aliases are substituted for their
+// canonical names when calling Sequence. An existing alias with the same short
+// name is replaced.
+// It returns an error if the canonical service isn't registered, or if the
+// alias collides with the name of a registered service.
+func (m Manager) Alias(short, canonical string) error {
+	if _, ok := m.srvcs[canonical]; !ok {
+		return fmt.Errorf("alias %q refers to unknown service %q", short, canonical)
+	}
+	if _, ok := m.srvcs[short]; ok {
+		return fmt.Errorf("alias %q collides with a registered service", short)
+	}
+
+	m.aliases[short] = canonical
+	return nil
+}
+
 // ServiceCount returns the number of services currently registered with the
 // Manager.
 func (m Manager) ServiceCount() uint16 {
@@ -301,31 +559,98 @@ func (m Manager) ServiceNames() []string {
 // Sequence takes a formula (see package-level comment)
 // and returns an Instance that acts as the main struct for calling Up() and
 // keeping track of progress.
+// A service name followed by a duration in braces, ie. "slow{5s}", limits the
+// time that the sequence waits for the service. Once it has passed, the service
+// function keeps running in a goroutine of its own, which leaks until the
+// function returns, as it can't be interrupted.
 func (m Manager) Sequence(form string) (Instance, error) {
 	i := Instance{}
 	i.mngr = m
+	i.agents = &agentSet{}
 
 	root, err := parse(form)
 	if err != nil {
 		return i, err
 	}
 
+	if err = m.resolveAliases(&root); err != nil {
+		return i, err
+	}
+
 	if err = m.checkNames(root); err != nil {
 		return i, err
 	}
 
+	if m.dedupe {
+		root.dedupe(make(map[string]bool))
+	}
+
 	i.root = root
 
 	return i, nil
 }
 
+// SequenceChecked works like Sequence, but rather than stopping at the first
+// unknown service name, it returns every unknown service name referenced by the
+// formula, in order of appearance and without repetitions, along with a single
+// ParseError. This allows for reporting all misspelled names at once. The slice
+// is nil if the formula doesn't reference any unknown services.
+func (m Manager) SequenceChecked(form string) (Instance, []string, error) {
+	root, err := parse(form)
+	if err != nil {
+		return Instance{}, nil, err
+	}
+
+	if err = m.resolveAliases(&root); err != nil {
+		return Instance{}, nil, err
+	}
+
+	var unknown, quoted []string
+	seen := make(map[string]bool)
+	for _, name := range root.Names() {
+		if _, ok := m.srvcs[name]; ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unknown = append(unknown, name)
+		quoted = append(quoted, "\""+name+"\"")
+	}
+	if len(unknown) > 0 {
+		details := "unknown services: " + strings.Join(quoted, ", ")
+		return Instance{}, unknown, newParseError(UnknownService, unknown[0], -1, details)
+	}
+
+	i, err := m.Sequence(form)
+	return i, nil, err
+}
+
+// resolveAliases takes the root step and runs through all child steps in order
+// to replace aliases with the canonical service names they refer to. It returns
+// a ParseError if an alias has since been registered as a service itself.
+func (m Manager) resolveAliases(st *step) error {
+	if canonical, ok := m.aliases[st.srvc]; ok {
+		if _, ok = m.srvcs[st.srvc]; ok {
+			return newParseError(AliasCollision, st.srvc, -1, "alias collides with a registered service: \""+st.srvc+"\"")
+		}
+		st.srvc = canonical
+	}
+
+	for curr := st.seq.head; curr != nil; curr = curr.next {
+		if err := m.resolveAliases(curr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // checkNames takes the root step and runs through all child steps in order
 // to check if the mentioned service name exists. It returns an appropriate
 // ParseError on the first missing/invalid service name.
 func (m Manager) checkNames(st step) error {
 	if st.srvc != "" {
 		if _, ok := m.srvcs[st.srvc]; !ok {
-			return newParseError("unknown service: \"" + st.srvc + "\"")
+			return newParseError(UnknownService, st.srvc, -1, "unknown service: \""+st.srvc+"\"")
 		}
 	}
 
@@ -345,8 +670,51 @@ func (m Manager) checkNames(st step) error {
 // during execution of the boot sequence. It also keeps track of progress
 // along the way, and provides the Up() method for starting the boot sequence.
 type Instance struct {
-	mngr Manager
-	root step
+	mngr   Manager
+	root   step
+	agents *agentSet // Running agents spawned from the Instance.
+}
+
+// agentSet keeps track of running agents along with the functions that cancel
+// their contexts. Agents are removed from the set once they complete.
+type agentSet struct {
+	sync.Mutex
+	cancels map[*Agent]context.CancelFunc
+}
+
+// add adds the given agent and its cancel function to the set.
+func (s *agentSet) add(a *Agent, cancel context.CancelFunc) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.cancels == nil {
+		s.cancels = make(map[*Agent]context.CancelFunc)
+	}
+	s.cancels[a] = cancel
+}
+
+// remove removes the given agent from the set.
+func (s *agentSet) remove(a *Agent) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	delete(s.cancels, a)
+}
+
+// cancelAll cancels the context of every agent in the set.
+func (s *agentSet) cancelAll() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
 }
 
 // CountSteps returns the number of steps currently added to the Instance.
@@ -357,36 +725,267 @@ func (i Instance) CountSteps() uint8 {
 	return countRecursively(i.root)
 }
 
+// WithManager returns a copy of the Instance that executes the same sequence
+// with the services of the given Manager instead, which separates the formula
+// from the service functions, ie. for running a sequence against test doubles.
+// Aliases were resolved when the formula was parsed, so the Manager must have
+// a service registered under each of the resolved names, or a ParseError is
+// returned. The copy doesn't share any running agents with the Instance.
+func (i Instance) WithManager(m Manager) (Instance, error) {
+	if err := m.checkNames(i.root); err != nil {
+		return Instance{}, err
+	}
+
+	return Instance{mngr: m, root: i.root.clone(), agents: &agentSet{}}, nil
+}
+
+// Then returns a new Instance that executes the sequence of the Instance,
+// followed by the sequence of other, as though their formulas were joined by
+// a '>'. The new Instance uses the Manager of the receiver, and doesn't share
+// any running agents with either Instance. Then panics if other references a
+// service that isn't registered with the Manager of the receiver.
+func (i Instance) Then(other Instance) Instance {
+	return i.join(other, serial)
+}
+
+// With returns a new Instance that executes the sequence of the Instance and
+// the sequence of other concurrently, as though their formulas were joined by
+// a ':'. Otherwise, it works like Then.
+func (i Instance) With(other Instance) Instance {
+	return i.join(other, parallel)
+}
+
+// join returns a new Instance with a root step in the given mode, containing
+// copies of the root steps of the Instance and other.
+func (i Instance) join(other Instance, md mode) Instance {
+	if err := i.mngr.checkNames(other.root); err != nil {
+		panic(panicCompose)
+	}
+
+	root := newStep("")
+	root.seq.mode = md
+	root.append(i.root.clone())
+	root.append(other.root.clone())
+	if i.mngr.dedupe {
+		root.dedupe(make(map[string]bool))
+	}
+
+	return Instance{mngr: i.mngr, root: root, agents: &agentSet{}}
+}
+
+// Names returns the service names of all steps in the Instance, in the order
+// in which they appear in the formula. A service that appears more than once
+// in the formula is listed as many times as it appears, so the number of names
+// always matches CountSteps.
+func (i Instance) Names() []string {
+	return i.root.Names()
+}
+
+// ReferencedServices returns the distinct service names used by the Instance,
+// in the order of their first appearance in the formula. Aliases are resolved
+// to the service names they refer to. Unlike Names, a service that appears more
+// than once is only listed once, which makes the result suitable for checking
+// the formula against an allow-list before executing it.
+func (i Instance) ReferencedServices() []string {
+	names := i.Names()
+	distinct := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			distinct = append(distinct, name)
+		}
+	}
+	return distinct
+}
+
+// Validate checks every service referenced by the Instance, and returns a
+// NilFuncError naming the first one that has a nil up or down function. Use it
+// to fail fast during configuration rather than in the middle of a sequence.
+func (i Instance) Validate() error {
+	for _, name := range i.Names() {
+		srvc := i.mngr.srvcs[name]
+		if srvc.up == nil || srvc.down == nil {
+			return NilFuncError(name)
+		}
+	}
+
+	return nil
+}
+
+// node is the JSON representation of a step: a service, or a group of steps
+// with a mode of "serial" or "parallel".
+type node struct {
+	Service  string `json:"service,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+	Children []node `json:"children,omitempty"`
+}
+
+// node returns the JSON representation of the step and its sub-steps.
+func (s step) node() node {
+	if s.seq.count == 0 {
+		n := node{Service: s.srvc}
+		if s.timeout > 0 {
+			n.Timeout = s.timeout.String()
+		}
+		return n
+	}
+
+	n := node{Mode: "serial", Limit: s.limit}
+	if s.seq.mode == parallel {
+		n.Mode = "parallel"
+	}
+	for curr := s.seq.head; curr != nil; curr = curr.next {
+		n.Children = append(n.Children, curr.node())
+	}
+	return n
+}
+
+// formula returns the formula for the node and its children. Groups are
+// enclosed in parenthesis, except for the root group unless it has a limit.
+func (n node) formula(root bool) (string, error) {
+	if len(n.Children) == 0 {
+		if n.Service == "" || n.Mode != "" || n.Limit != 0 {
+			return "", newParseError(InvalidNode, n.Service, -1, "node must be either a service or a group with children")
+		}
+		for _, r := range n.Service {
+			if !isNameRune(r) {
+				return "", newParseError(InvalidChar, string(r), -1, "invalid character(s) in service name")
+			}
+		}
+		if n.Timeout != "" {
+			return n.Service + "{" + n.Timeout + "}", nil
+		}
+		return n.Service, nil
+	}
+
+	if n.Service != "" || n.Timeout != "" {
+		return "", newParseError(InvalidNode, n.Service, -1, "node must be either a service or a group with children")
+	}
+	var op string
+	switch n.Mode {
+	case "serial":
+		op = string(serial)
+	case "parallel":
+		op = string(parallel)
+	default:
+		return "", newParseError(UnknownMode, n.Mode, -1, "unknown mode: \""+n.Mode+"\"")
+	}
+
+	forms := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		form, err := child.formula(false)
+		if err != nil {
+			return "", err
+		}
+		forms[i] = form
+	}
+	form := strings.Join(forms, op)
+	if !root || n.Limit > 0 {
+		form = "(" + form + ")"
+	}
+	if n.Limit > 0 {
+		form += "{" + strconv.Itoa(n.Limit) + "}"
+	}
+	return form, nil
+}
+
+// MarshalJSON returns the steps of the Instance as a nested JSON tree. Services
+// are represented as {"service":"a"}, along with a "timeout" if they have one,
+// and groups as {"mode":"parallel","children":[...]}, where the mode is either
+// "serial" or "parallel", along with a "limit" if they have one.
+func (i Instance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.root.node())
+}
+
+// UnmarshalFormula is the inverse of Instance.MarshalJSON. It returns an
+// Instance for the steps of the given JSON tree, which is validated just like
+// a formula passed to Sequence.
+func (m Manager) UnmarshalFormula(data []byte) (Instance, error) {
+	var n node
+	if err := json.Unmarshal(data, &n); err != nil {
+		return Instance{}, err
+	}
+
+	form, err := n.formula(true)
+	if err != nil {
+		return Instance{}, err
+	}
+
+	return m.Sequence(form)
+}
+
+// TotalSteps returns the number of steps across both the startup and the
+// shutdown sequence, ie. twice the number returned by CountSteps.
+func (i Instance) TotalSteps() uint16 {
+	return 2 * uint16(i.CountSteps())
+}
+
 // Up executes the startup phase, returning an agent for keeping track of, and
 // controlling the execution of the sequence.
 func (i Instance) Up(ctx context.Context) *Agent {
 	a := newAgent(i)
-	go a.exec(ctx)
+	a.start(ctx)
 
 	return a
 }
 
+// CancelAll cancels the context of every agent spawned from the Instance that
+// is still running, including agents for shutdown sequences. Agents that have
+// already completed are unaffected.
+func (i Instance) CancelAll() {
+	i.agents.cancelAll()
+}
+
 // Agent represents the execution of a sequence of steps. For any sequence,
 // there will be two agents in play: one for the bootup sequence, and another
 // for the shutdown sequence. The only difference between these two is the order
 // in which the sequence is executed.
 // Each agent keeps track of its progress and handles execution of sequence steps.
 type Agent struct {
-	sync.Mutex               // Controls access to Agent.callee.
+	sync.Mutex               // Controls access to Agent.callee, isDone, running, completed and closed.
 	phase      phase         // Current phase: up/down.
 	i          Instance      // Ref. to service functions via Instance.
 	callee     calleeDef     // Did client call Wait/Progress?
 	isDone     bool          // Did sequence execution complete?
 	prog       chan Progress // Progress reporting.
+	ctx        context.Context
+	up         *Agent        // Startup agent of a shutdown agent; see PrepareDown.
+	started    chan struct{} // Closed once the sequence has been started.
+	running    []string      // Services currently executing, in the order they started; see Current.
+	completed  []string      // Services that have completed, in the order they completed; see CompletionOrder.
+	closed     bool          // Has the progress channel been closed?
+	bestEffort bool          // Continue past failed steps? See WithBestEffort.
+}
+
+// DownOption configures a shutdown sequence; see Agent.Down.
+type DownOption func(a *Agent)
+
+// WithBestEffort makes the shutdown sequence attempt every step, even after
+// one of them has failed, so that no service is left running because of an
+// earlier failure. The result of each step is reported as usual, and Wait
+// returns the errors of all failed steps joined together. Cancellation still
+// stops the sequence right away.
+func WithBestEffort() DownOption {
+	return func(a *Agent) {
+		a.bestEffort = true
+	}
 }
 
 // newAgent correctly initializes and returns a new agent with the given Instance
-// embedded within.
+// embedded within. The progress channel can hold a report for every step, so
+// the sequence never blocks on a consumer that doesn't read them. Each phase
+// has its own channel, which is why the capacity is CountSteps rather than
+// TotalSteps: reports held back from the startup sequence never get in the way
+// of the shutdown sequence.
 func newAgent(i Instance) *Agent {
 	a := Agent{}
 	a.i = i
 	a.phase = phaseUp
 	a.prog = make(chan Progress, i.CountSteps())
+	a.started = make(chan struct{})
 	return &a
 }
 
@@ -396,16 +995,26 @@ func newAgent(i Instance) *Agent {
 // It returns true if the callee was successfully changed. It always returns
 // false when callee is calleeNone, which is useful.
 func (a *Agent) calleeIs(c calleeDef) bool {
+	ok, err := a.trySetCallee(c)
+	if err != nil {
+		panic(panicCallee)
+	}
+	return ok
+}
+
+// trySetCallee works like calleeIs, but returns a CalleeError rather than
+// panicking if the callee has already been set.
+func (a *Agent) trySetCallee(c calleeDef) (bool, error) {
 	a.Lock()
 	defer a.Unlock()
 	if c == calleeNone {
-		return false
+		return false, nil
 	}
 	if a.callee != calleeNone {
-		panic(panicCallee)
+		return false, CalleeError(panicCallee)
 	}
 	a.callee = c
-	return true
+	return true, nil
 }
 
 // Progress returns a channel that will receive a Progress struct every time
@@ -413,59 +1022,187 @@ func (a *Agent) calleeIs(c calleeDef) bool {
 // will stop and no further progress reports will be sent.
 // Consequently, there will either be a progress report for each step in the
 // sequence, or if execution stops short, the last progress report sent will
-// contain an error.
+// contain an error. If the context of the sequence is cancelled, the channel is
+// closed right after reporting the context error, without waiting for services
+// that are still executing.
 func (a *Agent) Progress() chan Progress {
 	a.calleeIs(calleeProg)
 	return a.prog
 }
 
-// Wait will block until execution of the boot sequence has completed.
-// It returns an error if any steps in the sequence failed.
+// TryProgress works like Progress, but returns a CalleeError rather than
+// panicking if the agent has already been waited on.
+func (a *Agent) TryProgress() (chan Progress, error) {
+	if _, err := a.trySetCallee(calleeProg); err != nil {
+		return nil, err
+	}
+	return a.prog, nil
+}
+
+// Wait will block until execution of the boot sequence has completed, or until
+// the context of the sequence is cancelled.
+// It returns an error if any steps in the sequence failed, or the context error
+// in case of cancellation, even if some steps are still running.
 func (a *Agent) Wait() error {
 	a.calleeIs(calleeWait)
+	return a.wait()
+}
 
-	for p := range a.prog {
-		if p.Err != nil {
-			return p.Err
-		}
+// TryWait works like Wait, but returns a CalleeError rather than panicking if
+// the agent has already been waited on.
+func (a *Agent) TryWait() error {
+	if _, err := a.trySetCallee(calleeWait); err != nil {
+		return err
 	}
+	return a.wait()
+}
 
-	return nil
+// wait blocks until execution of the boot sequence has completed, or until the
+// context of the sequence is cancelled. See Wait.
+// In best-effort mode, wait keeps reading reports after an error, and returns
+// the errors of every report joined together.
+func (a *Agent) wait() error {
+	<-a.started // The context is set once the sequence has been started.
+	done := a.ctx.Done()
+	var errs []error
+	for {
+		select {
+		case p, ok := <-a.prog:
+			if !ok {
+				return join(errs)
+			}
+			if p.Err != nil && !a.bestEffort {
+				return p.Err
+			}
+			if p.Err != nil {
+				errs = append(errs, p.Err)
+			}
+		case <-done:
+			a.Lock()
+			isDone := a.isDone
+			a.Unlock()
+			if !isDone {
+				return a.ctx.Err()
+			}
+			// The sequence completed before the context was cancelled, so
+			// finish reading the remaining reports.
+			done = nil
+		}
+	}
 }
 
 // Down starts the shutdown sequence. It returns a new agent for controlling
-// and monitoring execution of the sequence.
-func (a *Agent) Down(ctx context.Context) *Agent {
+// and monitoring execution of the sequence. By default, the sequence stops at
+// the first failed step, just like the startup sequence; see WithBestEffort.
+func (a *Agent) Down(ctx context.Context, opts ...DownOption) *Agent {
+	da := a.PrepareDown(opts...)
+	da.Start(ctx)
+
+	return da
+}
+
+// PrepareDown returns a new agent for the shutdown sequence without starting
+// it, so that a consumer can call Progress or Wait on it while the startup
+// sequence is still running. Call Start on the returned agent to execute the
+// shutdown sequence once the startup sequence has completed. The options are
+// those of Down.
+func (a *Agent) PrepareDown(opts ...DownOption) *Agent {
 	if a.phase == phaseDown {
 		// Down() has already been called once. Calling it again is a panic.
 		panic(panicDown)
 	}
 
-	a.Lock()
-	if !a.isDone {
-		// @TODO: Stop boot process and shutdown from current point in time.
-		// But for this initial version, we'll just panic.
-		a.Unlock()
-		panic(panicUp)
+	// The Instance is copied by Start, as it's in use until the startup sequence
+	// has completed. The capacity of the progress channel matches the step count.
+	da := Agent{}
+	da.phase = phaseDown
+	da.up = a
+	da.prog = make(chan Progress, cap(a.prog))
+	da.started = make(chan struct{})
+	for _, opt := range opts {
+		opt(&da)
 	}
-	a.Unlock()
 
-	da := newAgent(a.i)
-	da.phase = phaseDown
-	go da.exec(ctx)
+	return &da
+}
 
-	return da
+// Start executes the shutdown sequence of an agent returned by PrepareDown.
+// It panics if the startup sequence is still in progress, or if the agent has
+// already been started.
+func (a *Agent) Start(ctx context.Context) {
+	select {
+	case <-a.started:
+		panic(panicStarted)
+	default:
+	}
+
+	if a.up != nil {
+		a.up.Lock()
+		if !a.up.isDone {
+			// @TODO: Stop boot process and shutdown from current point in time.
+			// But for this initial version, we'll just panic.
+			a.up.Unlock()
+			panic(panicUp)
+		}
+		a.i = a.up.i
+		a.up.Unlock()
+	}
+
+	a.start(ctx)
+}
+
+// start executes the sequence in a new goroutine, using a cancellable context
+// derived from ctx which is registered with the agent's Instance.
+func (a *Agent) start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	a.ctx = ctx
+	close(a.started)
+	a.i.agents.add(a, cancel)
+
+	go func() {
+		a.exec(ctx)
+		a.i.agents.remove(a)
+		cancel()
+	}()
+	go func() {
+		<-ctx.Done() // Also done once the sequence has completed.
+		a.abort(ctx.Err())
+	}()
+}
+
+// abort reports the given context error and closes the progress channel right
+// away if the sequence hasn't completed yet, so that consumers ranging over the
+// channel aren't kept waiting for services that are still executing. The report
+// names the services that are still executing, if any. Reports sent by those
+// services once they complete are dropped.
+func (a *Agent) abort(err error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.prog <- Progress{strings.Join(a.running, ","), err}:
+	default: // A consumer that doesn't read mustn't block the abort.
+	}
+	a.closed = true
+	close(a.prog)
 }
 
 // report sends the provided message and/or error value on the progress channel
-// if, and only if, msg is non-empty and the client has called Wait/Progress.
+// if, and only if, msg is non-empty, the client has called Wait/Progress and
+// the channel hasn't been closed due to cancellation.
 func (a *Agent) report(msg string, err error) {
 	if msg == "" {
 		return
 	}
 
 	if !a.calleeIs(calleeNone) {
-		a.prog <- Progress{msg, err}
+		a.Lock()
+		defer a.Unlock()
+		if !a.closed {
+			a.prog <- Progress{msg, err}
+		}
 	}
 }
 
@@ -478,8 +1215,11 @@ func (a *Agent) exec(ctx context.Context) {
 	defer func() {
 		a.Lock()
 		a.isDone = true
+		if !a.closed {
+			a.closed = true
+			close(a.prog)
+		}
 		a.Unlock()
-		close(a.prog)
 	}()
 	_ = a.execStep(ctx, &a.i.root)
 	// @TODO: Log errors?
@@ -490,7 +1230,8 @@ func (a *Agent) exec(ctx context.Context) {
 // A Progress report is sent after execution of each step. If there's an error,
 // execution stops and the last Progress report will contain the relevant error.
 // In the case of parallel sequences, note that each parallel step will finish
-// execution even if one of them reports an error.
+// execution even if one of them reports an error. In best-effort mode, serial
+// sequences also continue past errors, unless the context is done.
 func (a *Agent) execStep(ctx context.Context, st *step) (err error) {
 	// Check if the context got cancelled.
 	select {
@@ -503,22 +1244,44 @@ func (a *Agent) execStep(ctx context.Context, st *step) (err error) {
 
 	// Execute the step.
 	if st.srvc != "" && st.seq.count == 0 {
-		g, _ := errgroup.WithContext(ctx)
-		fn := a.i.mngr.srvcs[st.srvc].byPhase(a.phase)
+		fn, phaseErr := a.i.mngr.srvcs[st.srvc].byPhase(a.phase)
+		if phaseErr != nil {
+			a.report(st.srvc, phaseErr)
+			err = phaseErr
+			return
+		}
+		if st.timeout > 0 {
+			fn = withTimeout(ctx, fn, st.timeout)
+		}
+		a.enter(st.srvc)
+		g := a.i.mngr.newRunner()
 		g.Go(wrapWithReporting(a, st.srvc, fn))
 		err = g.Wait()
+		a.leave(st.srvc)
 		return
 	}
 
 	// Execute the step sequence.
 	switch st.seq.mode {
 	case serial:
-		for curr := st.seq.first(a.phase); curr != nil && err == nil; curr = st.seq.next(a.phase) {
-			err = a.execStep(ctx, curr)
+		var errs []error
+		for curr := st.seq.first(a.phase); curr != nil; curr = st.seq.next(a.phase) {
+			stepErr := a.execStep(ctx, curr)
+			if stepErr == nil {
+				continue
+			}
+			errs = append(errs, stepErr)
+			if !a.bestEffort || ctx.Err() != nil {
+				break
+			}
 		}
+		err = join(errs)
 		return
 	case parallel:
-		g, _ := errgroup.WithContext(ctx)
+		g := a.i.mngr.newRunner()
+		if l, ok := g.(limiter); ok && st.limit > 0 {
+			l.SetLimit(st.limit)
+		}
 		for curr := st.seq.first(a.phase); curr != nil; curr = st.seq.next(a.phase) {
 			this := curr
 			g.Go(func() error {
@@ -527,14 +1290,68 @@ func (a *Agent) execStep(ctx context.Context, st *step) (err error) {
 		}
 		err = g.Wait()
 	default:
-		panic(panicUnknownMode)
+		a.report(st.String(), ErrUnknownMode)
+		err = ErrUnknownMode
 	}
 	return
 }
 
-func unspace(seq string) string {
-	re := regexp.MustCompile(`\s+`)
-	return re.ReplaceAllLiteralString(seq, "")
+// Current returns the name of the service that is currently executing, which
+// is a cheap alternative to consuming progress reports for a live status
+// display. When services of a parallel group are executing, their names are
+// joined by commas in the order they started. Current returns an empty string
+// when no service is executing, ie. before the sequence has been started and
+// after it has completed.
+func (a *Agent) Current() string {
+	a.Lock()
+	defer a.Unlock()
+	return strings.Join(a.running, ",")
+}
+
+// enter records that the service with the given name has started executing.
+func (a *Agent) enter(name string) {
+	a.Lock()
+	defer a.Unlock()
+	a.running = append(a.running, name)
+}
+
+// leave records that the service with the given name has stopped executing.
+// A service that appears more than once in the sequence is only removed once.
+func (a *Agent) leave(name string) {
+	a.Lock()
+	defer a.Unlock()
+	for i, running := range a.running {
+		if running == name {
+			a.running = append(a.running[:i], a.running[i+1:]...)
+			return
+		}
+	}
+}
+
+// CompletionOrder returns the names of the services that have completed
+// successfully, in the order in which they actually completed. Unlike the
+// formula, which only tells the planned order, this reflects how the services
+// of parallel groups happened to finish, which is useful for debugging timing
+// anomalies. A service that appears more than once in the sequence is listed
+// once per completion. The list is only final once the sequence has ended.
+func (a *Agent) CompletionOrder() []string {
+	a.Lock()
+	defer a.Unlock()
+	return append([]string(nil), a.completed...)
+}
+
+// complete records that the service with the given name has completed
+// successfully.
+func (a *Agent) complete(name string) {
+	a.Lock()
+	defer a.Unlock()
+	a.completed = append(a.completed, name)
+}
+
+// isNameRune returns true if the given rune is allowed in service names.
+func isNameRune(r rune) bool {
+	// Only allow ranges 0-9,a-z,A-Z, underscore and dash.
+	return (r >= 48 && r <= 57) || (r >= 65 && r <= 90) || (r >= 97 && r <= 122) || r == 95 || r == 45
 }
 
 // parse treats the given formula as a single group (it will wrap in parenthesis)
@@ -542,9 +1359,8 @@ func unspace(seq string) string {
 // An error is returned for empty sequences and illegal
 // characters. The returned step contains the entire sequence.
 func parse(form string) (step, error) {
-	form = unspace(form)
-	if form == "" {
-		return newStep(""), newParseError("empty sequence")
+	if strings.TrimSpace(form) == "" {
+		return newStep(""), newParseError(EmptySequence, "", -1, "empty sequence")
 	}
 
 	return parseFormula([]rune(form))
@@ -555,52 +1371,137 @@ func parse(form string) (step, error) {
 // are any sub-groups in the sequence, they are converted recursively into
 // sub-steps and added to the sequence. The given group should not
 // include the outermost pair of parentheses.
+// A group may be followed by a concurrency limit in braces, ie. "(a:b:c){2}".
 func parseFormula(form []rune) (step, error) {
 	var (
-		root   = newStep("")
-		next   step
-		word   = make([]rune, 0, 100)
-		parens uint8
+		root    = newStep("")
+		word    = make([]rune, 0, 100)
+		parens  uint8
+		timeout time.Duration // The timeout of the current word, if any.
+		closed  *step         // The group that was closed by the last parenthesis, if any.
+		operand bool          // Does the current position follow a service name or a group?
+		spaced  bool          // Does the current position follow whitespace?
+		opPos   = -1          // The position of an operator that still needs an operand, if any.
 	)
 
-	// Starting with seqMode = true, but this can change when we encounter the
-	// first symbol (":" or ">") that tells us what kind of step we're
-	// dealing with.
 	curr := &root
-	for _, r := range form {
+
+	// flush adds the current word, if any, as a step in the current sequence.
+	flush := func() {
+		if len(word) > 0 {
+			st := newStep(string(word))
+			st.timeout = timeout
+			curr.append(st)
+			word = word[:0]
+			timeout = 0
+		}
+	}
+
+	for pos := 0; pos < len(form); pos++ {
+		r := form[pos]
+		if unicode.IsSpace(r) {
+			spaced = true // Whitespace is allowed between tokens, but not inside them.
+			continue
+		}
+		if r != '{' {
+			closed = nil
+		}
+		afterSpace := spaced
+		spaced = false
 		switch r {
 		case '(':
+			if parens == math.MaxUint8 {
+				return root, newParseError(NestingTooDeep, "(", pos, "nesting too deep at position "+strconv.Itoa(pos))
+			}
 			curr.append(newStep(""))
 			curr = curr.seq.tail
 			parens++
+			operand = false
 		case ')':
+			if parens == 0 {
+				return root, newParseError(UnmatchedParen, ")", pos, "unmatched parenthesis")
+			}
+			if opPos >= 0 {
+				return root, newParseError(DanglingOperator, string(form[opPos]), opPos,
+					"dangling operator at position "+strconv.Itoa(opPos))
+			}
+			operand = true
+			flush()
+			closed = curr
 			curr = curr.parent
 			parens--
-		case ':':
-			if len(word) > 0 {
-				next = newStep(string(word))
-				curr.append(next)
-				curr.seq.mode = parallel
-				word = word[:0]
+		case ':', '>':
+			if !operand {
+				return root, newParseError(DanglingOperator, string(r), pos, "dangling operator at position "+strconv.Itoa(pos))
 			}
-		case '>':
-			if len(word) > 0 {
-				next := newStep(string(word))
-				curr.append(next)
-				curr.seq.mode = serial
-				word = word[:0]
+			operand = false
+			opPos = pos
+			flush()
+			curr.seq.mode = mode(r)
+		case '{':
+			if closed == nil && (len(word) == 0 || timeout > 0) {
+				return root, newParseError(MisplacedLimit, "{", pos, "concurrency limit must follow a group")
 			}
+			end := pos + 1
+			for end < len(form) && form[end] != '}' {
+				end++
+			}
+			if end == len(form) {
+				return root, newParseError(UnmatchedBrace, "{", pos, "unmatched brace")
+			}
+			arg := strings.TrimSpace(string(form[pos+1 : end]))
+			if closed == nil {
+				// A timeout for the current word. Plain numbers are taken for misplaced limits.
+				if _, err := strconv.Atoi(arg); err == nil {
+					return root, newParseError(MisplacedLimit, arg, pos, "concurrency limit must follow a group")
+				}
+				d, err := time.ParseDuration(arg)
+				if err != nil || d <= 0 {
+					start := pos + 1 // The position of the duration itself.
+					for unicode.IsSpace(form[start]) {
+						start++
+					}
+					return root, newParseError(InvalidTimeout, arg, start,
+						"invalid timeout: \""+arg+"\" at position "+strconv.Itoa(start))
+				}
+				timeout = d
+				pos = end
+				continue
+			}
+			limit, err := strconv.Atoi(arg)
+			if err != nil || limit < 1 {
+				return root, newParseError(InvalidLimit, arg, pos, "invalid concurrency limit: \""+arg+"\"")
+			}
+			if closed.seq.mode != parallel {
+				return root, newParseError(MisplacedLimit, arg, pos, "concurrency limit must follow a parallel group")
+			}
+			closed.limit = limit
+			closed = nil
+			pos = end
 		default:
-			// Only allow ranges 0-9,a-z,A-Z, underscore and dash.
-			if (r < 48 || r > 57) && (r < 65 || r > 90) && (r < 97 || r > 122) && r != 95 && r != 45 {
-				return root, newParseError("invalid character(s) in service name")
+			if !isNameRune(r) {
+				return root, newParseError(InvalidChar, string(r), pos, "invalid character(s) in service name")
+			}
+			if afterSpace && len(word) > 0 {
+				return root, newParseError(WhitespaceInName, string(word), pos,
+					"whitespace in service name at position "+strconv.Itoa(pos))
+			}
+			if timeout > 0 {
+				return root, newParseError(MisplacedTimeout, string(word), pos,
+					"timeout must end a service name at position "+strconv.Itoa(pos))
 			}
 			word = append(word, r)
+			operand = true
+			opPos = -1
 		}
 	}
 
 	if parens != 0 {
-		return root, newParseError("unmatched parenthesis")
+		return root, newParseError(UnmatchedParen, "(", -1, "unmatched parenthesis")
+	}
+	if opPos >= 0 {
+		return root, newParseError(DanglingOperator, string(form[opPos]), opPos,
+			"dangling operator at position "+strconv.Itoa(opPos))
 	}
 
 	// Handle the last unfinished word if we got one.
@@ -608,9 +1509,9 @@ func parseFormula(form []rune) (step, error) {
 		if root.seq.count == 0 {
 			// Edge case: replace word into root element.
 			root.srvc = string(word)
+			root.timeout = timeout
 		} else {
-			next := newStep(string(word))
-			curr.append(next)
+			flush()
 		}
 	}
 
@@ -636,15 +1537,44 @@ func countRecursively(st step) uint8 {
 
 // wrapWithReporting returns a function that, when called, calls the given
 // service function and sends a progress report using the given Agent before
-// returning the error (or nil in case of success).
+// returning the error (or nil in case of success). Successful completions are
+// recorded for CompletionOrder.
 func wrapWithReporting(a *Agent, name string, srvc Func) Func {
 	return func() error {
 		err := srvc()
+		if err == nil {
+			a.complete(name)
+		}
 		a.report(name, err)
 		return err
 	}
 }
 
+// withTimeout returns a function that, when called, calls the given service
+// function in a new goroutine and waits for it to return until the given
+// timeout has passed or ctx is done, in which case the context error is
+// returned. The service function itself isn't interrupted, as it doesn't
+// receive a context, and continues in the background: its goroutine leaks
+// until it returns.
+func withTimeout(ctx context.Context, srvc Func, timeout time.Duration) Func {
+	return func() error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- srvc()
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // Noop (no operation) is a convenience function you can use in place of a
 // step function for when you want a function that does nothing.
 func Noop() error {
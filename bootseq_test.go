@@ -2,10 +2,16 @@ package bootseq
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -19,23 +25,26 @@ func TestNew(t *testing.T) {
 }
 
 func TestService(t *testing.T) {
-	t.Run("it panics for unknown phase arguments", func(t *testing.T) {
-		defer verifyPanicWithMsg(t, panicUnknownPhase)
-
+	t.Run("it returns an error for unknown phase arguments", func(t *testing.T) {
 		s := service{Errop, Errop}
-		fn := s.byPhase(phase(8))
-		_ = fn()
-
-		t.Fatal("expected a panic") // Never called if panic is triggered.
+		fn, err := s.byPhase(phase(8))
+		if err != ErrUnknownPhase {
+			t.Fatalf("expected error %v, got %v", ErrUnknownPhase, err)
+		}
+		if fn != nil {
+			t.Fatal("expected a nil function")
+		}
 	})
 
 	t.Run("it returns the correct function by phase", func(t *testing.T) {
 		s := service{Noop, Errop}
-		fn := s.byPhase(phaseUp)
-		err := fn()
+		fn, err := s.byPhase(phaseUp)
+		verifyNilErr(t, err)
+		err = fn()
 		verifyNilErr(t, err)
 
-		fn = s.byPhase(phaseDown)
+		fn, err = s.byPhase(phaseDown)
+		verifyNilErr(t, err)
 		err = fn()
 		if err == nil || err != errStepFailure {
 			t.Fatalf("expected down function to return error value %q, got %v", errStepFailure, err)
@@ -79,6 +88,17 @@ func TestStep(t *testing.T) {
 		}
 	})
 
+	t.Run("it panics for unknown phase arguments", func(t *testing.T) {
+		// Agents only ever run in phaseUp or phaseDown, so this is unreachable.
+		s := newStep("test")
+		s.append(newStep("one"))
+
+		defer verifyPanicWithMsg(t, panicUnknownPhase)
+		_ = s.seq.first(phase(8))
+
+		t.Fatal("expected a panic") // Never called if panic is triggered.
+	})
+
 	t.Run("it tracks the correct number of steps", func(t *testing.T) {
 		s := newStep("test")
 		s.append(newStep("one"))
@@ -144,11 +164,49 @@ func TestManager_Add(t *testing.T) {
 			mgr.Add("Service #"+strconv.Itoa(i), Noop, Noop)
 		}
 
-		defer verifyPanicWithMsg(t, panicServiceLimit)
+		defer verifyPanicWithMsg(t, "reached limit of max 65535 services")
 		mgr.Add("Service #65536", Noop, Noop)
 
 		t.Fatal("expected to panic on the 65536th service")
 	})
+
+	t.Run("panics if more services are registered than the configured limit", func(t *testing.T) {
+		mgr := New("Small one").WithServiceLimit(2)
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("two", Noop, Noop) // Replacing a service is always allowed.
+
+		defer verifyPanicWithMsg(t, "reached limit of max 2 services")
+		mgr.Add("three", Noop, Noop)
+
+		t.Fatal("expected to panic on the third service")
+	})
+}
+
+func TestManager_WithRunner(t *testing.T) {
+	t.Run("uses the provided runner for every step", func(t *testing.T) {
+		runner := &serialRunner{}
+		mgr := New("Custom runner").WithRunner(func() Runner { return runner })
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+		i, err := mgr.Sequence("one > (two : three)")
+		verifyNilErr(t, err)
+
+		err = i.Up(context.Background()).Wait()
+		verifyNilErr(t, err)
+
+		// One call per service step, plus one per member of the parallel group.
+		verifyCountEq(t, uint32(runner.calls), 5)
+	})
+
+	t.Run("does not affect the original manager", func(t *testing.T) {
+		mgr := New("Default runner")
+		_ = mgr.WithRunner(func() Runner { return &serialRunner{} })
+		if _, ok := mgr.newRunner().(*serialRunner); ok {
+			t.Fatal("expected original manager to keep its default runner")
+		}
+	})
 }
 
 func TestManager_Sequence(t *testing.T) {
@@ -191,9 +249,9 @@ func TestManager_Sequence(t *testing.T) {
 	})
 
 	t.Run("calls repeated service names the correct number of times", func(t *testing.T) {
-		var called uint8
+		var called uint32
 		incop := func() error {
-			called++
+			atomic.AddUint32(&called, 1) // Both steps of the parallel group run concurrently.
 			return nil
 		}
 		mgr := New("Invalid #4")
@@ -208,9 +266,9 @@ func TestManager_Sequence(t *testing.T) {
 			t.Fatalf("failed waiting for bootup sequence: %s", err.Error())
 		}
 
-		expected := uint8(2)
-		if called != expected {
-			t.Fatalf("expected step two to increment the counter to %d, got %d", expected, called)
+		expected := uint32(2)
+		if actual := atomic.LoadUint32(&called); actual != expected {
+			t.Fatalf("expected step two to increment the counter to %d, got %d", expected, actual)
 		}
 	})
 
@@ -231,6 +289,121 @@ func TestManager_Sequence(t *testing.T) {
 	})
 }
 
+func TestManager_WithDedupeSteps(t *testing.T) {
+	t.Run("runs repeated services once", func(t *testing.T) {
+		var called uint8
+		incop := func() error {
+			called++
+			return nil
+		}
+		mgr := New("Dedupe").WithDedupeSteps()
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", incop, Noop)
+		mgr.Add("three", Noop, Noop)
+		i, err := mgr.Sequence("one > (two : two : three) > two")
+		verifyNilErr(t, err)
+		verifyStringSlicesEqual(t, i.Names(), []string{"one", "two", "three"})
+
+		up := i.Up(context.Background())
+		verifyNilErr(t, up.Wait())
+		verifyCountEq(t, uint32(called), 1)
+	})
+
+	t.Run("removes groups that are left empty", func(t *testing.T) {
+		mgr := New("Dedupe Groups").WithDedupeSteps()
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		i, err := mgr.Sequence("one > (one : one) > two")
+		verifyNilErr(t, err)
+		verifyStringSlicesEqual(t, i.Names(), []string{"one", "two"})
+		verifyCountEq(t, uint32(i.CountSteps()), 2)
+	})
+
+	t.Run("doesn't affect the original Manager", func(t *testing.T) {
+		mgr := New("Dedupe Copy")
+		mgr.Add("one", Noop, Noop)
+		_ = mgr.WithDedupeSteps()
+		i, err := mgr.Sequence("one > one")
+		verifyNilErr(t, err)
+		verifyCountEq(t, uint32(i.CountSteps()), 2)
+	})
+}
+
+func TestManager_SequenceChecked(t *testing.T) {
+	mgr := New("Checked")
+	mgr.Add("one", Noop, Noop)
+	mgr.Add("two", Noop, Noop)
+	mgr.Alias("first", "one")
+
+	t.Run("returns every unknown service", func(t *testing.T) {
+		_, unknown, err := mgr.SequenceChecked("one > (tow : three) > tow > four")
+		verifyParseError(t, err, `unknown services: "tow", "three", "four"`)
+		verifyStringSlicesEqual(t, []string{"tow", "three", "four"}, unknown)
+	})
+
+	t.Run("returns the instance for known services", func(t *testing.T) {
+		i, unknown, err := mgr.SequenceChecked("first > two")
+		verifyNilErr(t, err)
+		if unknown != nil {
+			t.Fatalf("expected no unknown services, got %v", unknown)
+		}
+		verifyStringSlicesEqual(t, []string{"one", "two"}, i.Names())
+	})
+
+	t.Run("returns syntax errors", func(t *testing.T) {
+		_, unknown, err := mgr.SequenceChecked("one >")
+		verifyParseError(t, err, "dangling operator")
+		if unknown != nil {
+			t.Fatalf("expected no unknown services, got %v", unknown)
+		}
+	})
+}
+
+func TestManager_Alias(t *testing.T) {
+	t.Run("substitutes aliases in formulas", func(t *testing.T) {
+		mgr := New("Aliased boot sequence")
+		mgr.Add("configuration", Noop, Noop)
+		mgr.Add("database", Noop, Noop)
+		verifyNilErr(t, mgr.Alias("cfg", "configuration"))
+		verifyNilErr(t, mgr.Alias("db", "database"))
+		i, err := mgr.Sequence("cfg > (db : configuration)")
+		verifyNilErr(t, err)
+
+		actual := i.root.String()
+		expected := "(configuration>(database:configuration))"
+		if actual != expected {
+			t.Fatalf("expected formula %q, got %q", expected, actual)
+		}
+	})
+
+	t.Run("returns an error for an unknown canonical service", func(t *testing.T) {
+		mgr := New("Aliased boot sequence")
+		err := mgr.Alias("db", "database")
+		if err == nil || err.Error() != "alias \"db\" refers to unknown service \"database\"" {
+			t.Fatalf("expected an unknown service error, got %v", err)
+		}
+	})
+
+	t.Run("returns an error for an alias that collides with a service", func(t *testing.T) {
+		mgr := New("Aliased boot sequence")
+		mgr.Add("db", Noop, Noop)
+		mgr.Add("database", Noop, Noop)
+		err := mgr.Alias("db", "database")
+		if err == nil || err.Error() != "alias \"db\" collides with a registered service" {
+			t.Fatalf("expected a collision error, got %v", err)
+		}
+	})
+
+	t.Run("returns an error for a service added after its alias", func(t *testing.T) {
+		mgr := New("Aliased boot sequence")
+		mgr.Add("database", Noop, Noop)
+		verifyNilErr(t, mgr.Alias("db", "database"))
+		mgr.Add("db", Noop, Noop)
+		_, err := mgr.Sequence("db")
+		verifyParseError(t, err, "alias collides with a registered service: \"db\"")
+	})
+}
+
 func TestInstance_CountSteps(t *testing.T) {
 	t.Run("returns the correct step count (simple case)", func(t *testing.T) {
 		mgr := New("Count Test Simple")
@@ -294,6 +467,282 @@ func TestInstance_CountSteps(t *testing.T) {
 	})
 }
 
+func TestInstance_Names(t *testing.T) {
+	t.Run("lists service names in formula order", func(t *testing.T) {
+		mgr := New("Names")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+
+		i, err := mgr.Sequence("one > (three : two)")
+		verifyNilErr(t, err)
+		verifyStringSlicesEqual(t, i.Names(), []string{"one", "three", "two"})
+	})
+
+	t.Run("lists repeated service names once per occurrence", func(t *testing.T) {
+		mgr := New("Names Repeated")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+
+		i, err := mgr.Sequence("one > (two : two) > one")
+		verifyNilErr(t, err)
+		verifyStringSlicesEqual(t, i.Names(), []string{"one", "two", "two", "one"})
+		verifyCountEq(t, uint32(len(i.Names())), uint32(i.CountSteps()))
+	})
+}
+
+func TestInstance_ThenWith(t *testing.T) {
+	mgr := New("Composed")
+	mgr.Add("one", Noop, Noop)
+	mgr.Add("two", Noop, Noop)
+	mgr.Add("three", Noop, Noop)
+	a, err := mgr.Sequence("one > two")
+	verifyNilErr(t, err)
+	b, err := mgr.Sequence("three")
+	verifyNilErr(t, err)
+
+	t.Run("it joins sequences serially", func(t *testing.T) {
+		i := a.Then(b)
+		verifyStringSlicesEqual(t, []string{"one", "two", "three"}, i.Names())
+		actual := make([]string, 0, 3)
+		for p := range i.Up(context.Background()).Progress() {
+			verifyNilErr(t, p.Err)
+			actual = append(actual, p.Service)
+		}
+		verifyStringSlicesEqual(t, []string{"one", "two", "three"}, actual)
+	})
+
+	t.Run("it joins sequences concurrently", func(t *testing.T) {
+		i := b.With(a)
+		if actual, expected := i.root.String(), "(three:(one>two))"; actual != expected {
+			t.Fatalf("expected Instance.With() to result in %q, got %q", expected, actual)
+		}
+		verifyNilErr(t, i.Up(context.Background()).Wait())
+	})
+
+	t.Run("it leaves the original instances untouched", func(t *testing.T) {
+		_ = a.Then(b).With(b)
+		verifyStringSlicesEqual(t, []string{"one", "two"}, a.Names())
+		verifyStringSlicesEqual(t, []string{"three"}, b.Names())
+	})
+
+	t.Run("it panics for incompatible managers", func(t *testing.T) {
+		other := New("Other")
+		other.Add("four", Noop, Noop)
+		c, err := other.Sequence("four")
+		verifyNilErr(t, err)
+
+		defer verifyPanicWithMsg(t, panicCompose)
+		_ = a.Then(c)
+		t.Fatal("expected to panic")
+	})
+}
+
+func TestInstance_ReferencedServices(t *testing.T) {
+	mgr := New("Referenced")
+	mgr.Add("one", Noop, Noop)
+	mgr.Add("two", Noop, Noop)
+	mgr.Add("three", Noop, Noop)
+	mgr.Alias("first", "one")
+
+	i, err := mgr.Sequence("two > (first : three) > one > two")
+	verifyNilErr(t, err)
+	verifyStringSlicesEqual(t, []string{"two", "one", "three"}, i.ReferencedServices())
+}
+
+func TestInstance_Validate(t *testing.T) {
+	t.Run("accepts services with up and down funcs", func(t *testing.T) {
+		mgr := New("Validate")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+
+		i, err := mgr.Sequence("one > two")
+		verifyNilErr(t, err)
+		verifyNilErr(t, i.Validate())
+	})
+
+	t.Run("returns the first service with a nil func", func(t *testing.T) {
+		mgr := New("Validate Nil")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, nil)
+		mgr.Add("three", nil, Noop)
+
+		i, err := mgr.Sequence("one > (two : three)")
+		verifyNilErr(t, err)
+		err = i.Validate()
+		if err != NilFuncError("two") {
+			t.Fatalf("expected NilFuncError for service two, got %v", err)
+		}
+	})
+}
+
+func TestInstance_WithManager(t *testing.T) {
+	var calls []string
+	call := func(name string) Func {
+		return func() error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	prod := New("Production")
+	prod.Add("one", call("prod one"), Noop)
+	prod.Add("two", call("prod two"), Noop)
+	i, err := prod.Sequence("one > two{1s}")
+	verifyNilErr(t, err)
+
+	t.Run("it runs the sequence with the services of another manager", func(t *testing.T) {
+		calls = nil
+		doubles := New("Test Doubles")
+		doubles.Add("one", call("double one"), Noop)
+		doubles.Add("two", call("double two"), Noop)
+		rebound, err := i.WithManager(doubles)
+		verifyNilErr(t, err)
+		verifyStringSlicesEqual(t, i.Names(), rebound.Names())
+		verifyNilErr(t, rebound.Up(context.Background()).Wait())
+		verifyStringSlicesEqual(t, []string{"double one", "double two"}, calls)
+
+		calls = nil
+		verifyNilErr(t, i.Up(context.Background()).Wait())
+		verifyStringSlicesEqual(t, []string{"prod one", "prod two"}, calls)
+	})
+
+	t.Run("it returns an error for missing services", func(t *testing.T) {
+		doubles := New("Test Doubles")
+		doubles.Add("one", Noop, Noop)
+		_, err := i.WithManager(doubles)
+		verifyParseError(t, err, `unknown service: "two"`)
+	})
+}
+
+func TestInstance_MarshalJSON(t *testing.T) {
+	mgr := New("JSON")
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		mgr.Add(name, Noop, Noop)
+	}
+
+	t.Run("marshals services and groups as a tree", func(t *testing.T) {
+		i, err := mgr.Sequence("a > (b : c{5s}){2} > d")
+		verifyNilErr(t, err)
+
+		data, err := json.Marshal(i)
+		verifyNilErr(t, err)
+		expected := `{"mode":"serial","children":[{"service":"a"},` +
+			`{"mode":"parallel","limit":2,"children":[{"service":"b"},{"service":"c","timeout":"5s"}]},` +
+			`{"service":"d"}]}`
+		if string(data) != expected {
+			t.Fatalf("expected %s, got %s", expected, data)
+		}
+	})
+
+	t.Run("round-trips with UnmarshalFormula", func(t *testing.T) {
+		forms := []string{"a", "a{1s}", "a>b", "(a>b)", "(a:b){2}", "a>(b:c:d){2}>e", "(a:(b:c){1}:d)>e", "((a:b)>c)"}
+		for _, form := range forms {
+			i, err := mgr.Sequence(form)
+			verifyNilErr(t, err)
+			data, err := i.MarshalJSON()
+			verifyNilErr(t, err)
+
+			actual, err := mgr.UnmarshalFormula(data)
+			verifyNilErr(t, err)
+			if actual.root.String() != i.root.String() {
+				t.Fatalf("expected %q to round-trip as %q, got %q", form, i.root.String(), actual.root.String())
+			}
+			verifyStringSlicesEqual(t, i.Names(), actual.Names())
+		}
+	})
+}
+
+func TestManager_UnmarshalFormula(t *testing.T) {
+	mgr := New("JSON")
+	mgr.Add("a", Noop, Noop)
+	mgr.Add("b", Noop, Noop)
+
+	t.Run("returns an error for invalid trees", func(t *testing.T) {
+		cases := map[string]string{
+			`{}`: "node must be either a service or a group with children",
+			`{"service":"a","children":[{"service":"b"}]}`:   "node must be either a service or a group with children",
+			`{"mode":"random","children":[{"service":"a"}]}`: "unknown mode: \"random\"",
+			`{"service":"a>b"}`:                              "invalid character(s) in service name",
+			`{"service":"x"}`:                                "unknown service: \"x\"",
+		}
+		for in, expected := range cases {
+			_, err := mgr.UnmarshalFormula([]byte(in))
+			verifyParseError(t, err, expected)
+		}
+	})
+
+	t.Run("returns an error for invalid JSON", func(t *testing.T) {
+		_, err := mgr.UnmarshalFormula([]byte(`{`))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestInstance_TotalSteps(t *testing.T) {
+	t.Run("returns twice the step count", func(t *testing.T) {
+		mgr := New("Total Steps")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+
+		i, err := mgr.Sequence("one > (two : three)")
+		verifyNilErr(t, err)
+		verifyCountEq(t, uint32(i.TotalSteps()), 6)
+	})
+
+	t.Run("does not overflow near the step limit", func(t *testing.T) {
+		mgr := New("Total Steps Limit")
+		names := make([]string, 0, 200)
+		for i := 1; i <= 200; i++ {
+			name := "s" + strconv.Itoa(i)
+			mgr.Add(name, Noop, Noop)
+			names = append(names, name)
+		}
+
+		i, err := mgr.Sequence(strings.Join(names, ">"))
+		verifyNilErr(t, err)
+		verifyCountEq(t, uint32(i.TotalSteps()), 400)
+	})
+}
+
+func TestInstance_CancelAll(t *testing.T) {
+	t.Run("it cancels every running agent", func(t *testing.T) {
+		mgr := New("Cancel All")
+		mgr.Add("one", Sleepop, Noop)
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		up1 := i.Up(context.Background())
+		up2 := i.Up(context.Background())
+		i.CancelAll()
+
+		if err = up1.Wait(); err != context.Canceled {
+			t.Fatalf("expected first agent to return %v, got %v", context.Canceled, err)
+		}
+		if err = up2.Wait(); err != context.Canceled {
+			t.Fatalf("expected second agent to return %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("it does not affect completed agents", func(t *testing.T) {
+		mgr := New("Cancel All")
+		mgr.Add("one", Noop, Noop)
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		pp := up.Progress()
+		for range pp {
+		}
+		i.CancelAll()
+
+		down := up.Down(context.Background())
+		verifyNilErr(t, down.Wait())
+	})
+}
+
 func TestAgent_Up(t *testing.T) {
 	t.Run("it returns a channel with capacity matching step count", func(t *testing.T) {
 		mgr := New("Three-step boot sequence")
@@ -332,98 +781,443 @@ func TestAgent_Up(t *testing.T) {
 			t.Fatalf("expected Agent.Up() to result in %q, got %q", expected, actual)
 		}
 	})
-}
+}
+
+func TestAgent_Down(t *testing.T) {
+	t.Run("returns channel with capacity matching step count", func(t *testing.T) {
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		down := up.Down(context.Background())
+
+		p := down.Progress()
+		verifyChannelCap(t, p, 3)
+	})
+
+	t.Run("it runs steps in reverse order", func(t *testing.T) {
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		down := up.Down(context.Background())
+
+		pp := down.Progress()
+		names := make([]string, 0, 3)
+		for p := range pp {
+			msg := p.Service
+			if p.Err != nil {
+				msg = p.Err.Error()
+			}
+			names = append(names, msg)
+		}
+		actual := strings.Join(names, ",")
+		expected := "three,two,one"
+		if actual != expected {
+			t.Fatalf("expected Agent.Down() to result in %q, got %q", expected, actual)
+		}
+	})
+
+	t.Run("it runs steps in reverse order (advanced case)", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Add("one", Noop, Panicop) // Panicop should never execute.
+		mgr.Add("two", Noop, Errop)   // Fails on fifth Down step.
+		mgr.Add("three", Noop, Noop)
+		mgr.Add("four", Noop, Noop)
+		mgr.Add("five", Noop, Noop)
+		mgr.Add("six", Noop, Noop)
+		i, err := mgr.Sequence("one > two > (three : four : five) > six")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		down := up.Down(context.Background())
+
+		pp := down.Progress()
+		actual := make([]string, 0, 5)
+		for p := range pp {
+			msg := p.Service
+			if p.Err != nil {
+				msg = p.Err.Error()
+			}
+			actual = append(actual, msg)
+		}
+
+		expected := []string{errStepFailure.Error(), "three", "four", "five", "six"}
+		verifyStringSlicesEqual(t, expected, actual)
+	})
+
+	t.Run("it attempts every step in best-effort mode", func(t *testing.T) {
+		errOther := errors.New("other step has failed")
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Errop)
+		mgr.Add("three", Noop, func() error { return errOther })
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		pp := up.Down(context.Background(), WithBestEffort()).Progress()
+		actual := make([]string, 0, 3)
+		for p := range pp {
+			msg := p.Service
+			if p.Err != nil {
+				msg = p.Err.Error()
+			}
+			actual = append(actual, msg)
+		}
+
+		expected := []string{errOther.Error(), errStepFailure.Error(), "one"}
+		verifyStringSlicesEqual(t, expected, actual)
+	})
+
+	t.Run("it joins the errors of every failed step in best-effort mode", func(t *testing.T) {
+		errOther := errors.New("other step has failed")
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Noop, Errop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, func() error { return errOther })
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		err = up.Down(context.Background(), WithBestEffort()).Wait()
+		if !errors.Is(err, errStepFailure) || !errors.Is(err, errOther) {
+			t.Fatalf("expected Agent.Wait() to return both step errors, got %v", err)
+		}
+	})
+
+	t.Run("it returns a single error as is", func(t *testing.T) {
+		mgr := New("Two-step boot sequence")
+		mgr.Add("one", Noop, Errop)
+		mgr.Add("two", Noop, Noop)
+		i, err := mgr.Sequence("one > two")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		err = up.Down(context.Background(), WithBestEffort()).Wait()
+		if err != errStepFailure {
+			t.Fatalf("expected Agent.Wait() to return %v, got %#v", errStepFailure, err)
+		}
+	})
+
+	t.Run("it stops at the first failed step by default", func(t *testing.T) {
+		mgr := New("Two-step boot sequence")
+		mgr.Add("one", Noop, Panicop) // Panicop should never execute.
+		mgr.Add("two", Noop, Errop)
+		i, err := mgr.Sequence("one > two")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		_ = up.Wait()
+
+		err = up.Down(context.Background()).Wait()
+		if err != errStepFailure {
+			t.Fatalf("expected Agent.Wait() to return %v, got %v", errStepFailure, err)
+		}
+	})
+
+	t.Run("it does not block while startup reports are held", func(t *testing.T) {
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+		i, err := mgr.Sequence("one > (two : three)")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		held := up.Progress()
+		for {
+			up.Lock()
+			isDone := up.isDone
+			up.Unlock()
+			if isDone {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- up.Down(context.Background()).Wait()
+		}()
+		select {
+		case err := <-done:
+			verifyNilErr(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected shutdown sequence to complete while startup reports are held")
+		}
+
+		var count uint32
+		for range held {
+			count++
+		}
+		verifyCountEq(t, count, uint32(i.CountSteps()))
+	})
+
+	t.Run("it panics if called while booting up", func(t *testing.T) {
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Sleepop, Noop)
+		mgr.Add("two", Sleepop, Noop)
+		mgr.Add("three", Sleepop, Noop)
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+
+		defer verifyPanicWithMsg(t, panicUp)
+		_ = up.Down(context.Background())
+		t.Fatal("expected to panic")
+	})
+}
+
+func TestAgent_PrepareDown(t *testing.T) {
+	t.Run("delivers progress to a consumer attached before startup completes", func(t *testing.T) {
+		mgr := New("Three-step boot sequence")
+		mgr.Add("one", Sleepop, Noop)
+		mgr.Add("two", Noop, Noop)
+		mgr.Add("three", Noop, Noop)
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		down := up.PrepareDown()
+
+		names := make(chan []string)
+		pp := down.Progress()
+		go func() {
+			actual := make([]string, 0, 3)
+			for p := range pp {
+				actual = append(actual, p.Service)
+			}
+			names <- actual
+		}()
+
+		verifyNilErr(t, up.Wait())
+		down.Start(context.Background())
+		verifyStringSlicesEqual(t, []string{"three", "two", "one"}, <-names)
+	})
+
+	t.Run("waits for the agent to be started", func(t *testing.T) {
+		mgr := New("One-step boot sequence")
+		mgr.Add("one", Noop, Errop)
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		down := up.PrepareDown()
+
+		result := make(chan error)
+		go func() {
+			result <- down.Wait()
+		}()
+
+		verifyNilErr(t, up.Wait())
+		down.Start(context.Background())
+		if err = <-result; err != errStepFailure {
+			t.Fatalf("expected %v, got %v", errStepFailure, err)
+		}
+	})
+
+	t.Run("it panics if started while booting up", func(t *testing.T) {
+		mgr := New("One-step boot sequence")
+		mgr.Add("one", Sleepop, Noop)
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		down := up.PrepareDown()
+
+		defer verifyPanicWithMsg(t, panicUp)
+		down.Start(context.Background())
+		t.Fatal("expected to panic")
+	})
 
-func TestAgent_Down(t *testing.T) {
-	t.Run("returns channel with capacity matching step count", func(t *testing.T) {
-		mgr := New("Three-step boot sequence")
+	t.Run("it panics if started twice", func(t *testing.T) {
+		mgr := New("One-step boot sequence")
 		mgr.Add("one", Noop, Noop)
-		mgr.Add("two", Noop, Noop)
-		mgr.Add("three", Noop, Noop)
-		i, err := mgr.Sequence("one > two > three")
+		i, err := mgr.Sequence("one")
 		verifyNilErr(t, err)
 
 		up := i.Up(context.Background())
 		_ = up.Wait()
-
 		down := up.Down(context.Background())
 
-		p := down.Progress()
-		verifyChannelCap(t, p, 3)
+		defer verifyPanicWithMsg(t, panicStarted)
+		down.Start(context.Background())
+		t.Fatal("expected to panic")
 	})
+}
 
-	t.Run("it runs steps in reverse order", func(t *testing.T) {
-		mgr := New("Three-step boot sequence")
-		mgr.Add("one", Noop, Noop)
-		mgr.Add("two", Noop, Noop)
-		mgr.Add("three", Noop, Noop)
-		i, err := mgr.Sequence("one > two > three")
+func TestAgent_Timeout(t *testing.T) {
+	t.Run("stops waiting for a service when its timeout expires", func(t *testing.T) {
+		mgr := New("Timed boot sequence")
+		mgr.Add("slow", func() error {
+			time.Sleep(250 * time.Millisecond)
+			return nil
+		}, Noop)
+		mgr.Add("fast", Panicop, Noop) // Panicop should never execute.
+		i, err := mgr.Sequence("slow{10ms} > fast")
 		verifyNilErr(t, err)
 
-		up := i.Up(context.Background())
-		_ = up.Wait()
+		err = i.Up(context.Background()).Wait()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected error %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
 
-		down := up.Down(context.Background())
+	t.Run("returns the service error within the timeout", func(t *testing.T) {
+		mgr := New("Timed boot sequence")
+		mgr.Add("quick", Errop, Noop)
+		i, err := mgr.Sequence("quick{1s}")
+		verifyNilErr(t, err)
 
-		pp := down.Progress()
-		names := make([]string, 0, 3)
-		for p := range pp {
-			msg := p.Service
-			if p.Err != nil {
-				msg = p.Err.Error()
-			}
-			names = append(names, msg)
+		err = i.Up(context.Background()).Wait()
+		if err != errStepFailure {
+			t.Fatalf("expected error %v, got %v", errStepFailure, err)
 		}
-		actual := strings.Join(names, ",")
-		expected := "three,two,one"
-		if actual != expected {
-			t.Fatalf("expected Agent.Down() to result in %q, got %q", expected, actual)
+	})
+}
+
+func TestAgent_Limit(t *testing.T) {
+	t.Run("it limits the concurrency of a group", func(t *testing.T) {
+		var active, max int32
+		op := func() error {
+			n := atomic.AddInt32(&active, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			return nil
 		}
+
+		mgr := New("Limited")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", op, Noop)
+		mgr.Add("three", op, Noop)
+		mgr.Add("four", op, Noop)
+		mgr.Add("five", op, Noop)
+		i, err := mgr.Sequence("one > (two : three : four : five){2}")
+		verifyNilErr(t, err)
+
+		err = i.Up(context.Background()).Wait()
+		verifyNilErr(t, err)
+		verifyCountEq(t, uint32(max), 2)
 	})
+}
 
-	t.Run("it runs steps in reverse order (advanced case)", func(t *testing.T) {
-		mgr := New("Boot it!")
-		mgr.Add("one", Noop, Panicop) // Panicop should never execute.
-		mgr.Add("two", Noop, Errop)   // Fails on fifth Down step.
-		mgr.Add("three", Noop, Noop)
+func TestAgent_Current(t *testing.T) {
+	entered := make(chan string)
+	release := make(chan struct{})
+	op := func(name string) Func {
+		return func() error {
+			entered <- name
+			<-release
+			return nil
+		}
+	}
+
+	mgr := New("Current Steps")
+	mgr.Add("one", op("one"), Noop)
+	mgr.Add("two", op("two"), Noop)
+	mgr.Add("three", op("three"), Noop)
+	i, err := mgr.Sequence("one > (two : three)")
+	verifyNilErr(t, err)
+
+	up := i.Up(context.Background())
+	verifyStringSlicesEqual(t, []string{"one"}, []string{<-entered})
+	verifyStringSlicesEqual(t, []string{"one"}, []string{up.Current()})
+	release <- struct{}{}
+
+	names := []string{<-entered, <-entered}
+	sort.Strings(names)
+	current := strings.Split(up.Current(), ",")
+	sort.Strings(current)
+	verifyStringSlicesEqual(t, names, current)
+	close(release)
+
+	err = up.Wait()
+	verifyNilErr(t, err)
+	verifyStringSlicesEqual(t, []string{""}, []string{up.Current()})
+}
+
+func TestAgent_CompletionOrder(t *testing.T) {
+	t.Run("it records the order in which services completed", func(t *testing.T) {
+		release := map[string]chan struct{}{
+			"two":   make(chan struct{}),
+			"three": make(chan struct{}),
+		}
+		op := func(name string) Func {
+			return func() error {
+				<-release[name]
+				return nil
+			}
+		}
+
+		mgr := New("Completion Order")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", op("two"), Noop)
+		mgr.Add("three", op("three"), Noop)
 		mgr.Add("four", Noop, Noop)
-		mgr.Add("five", Noop, Noop)
-		mgr.Add("six", Noop, Noop)
-		i, err := mgr.Sequence("one > two > (three : four : five) > six")
+		i, err := mgr.Sequence("one > (two : three) > four")
 		verifyNilErr(t, err)
 
 		up := i.Up(context.Background())
-		_ = up.Wait()
-
-		down := up.Down(context.Background())
-
-		pp := down.Progress()
-		actual := make([]string, 0, 5)
+		pp := up.Progress()
+		close(release["three"])
 		for p := range pp {
-			msg := p.Service
-			if p.Err != nil {
-				msg = p.Err.Error()
+			verifyNilErr(t, p.Err)
+			if p.Service == "three" {
+				break
 			}
-			actual = append(actual, msg)
 		}
+		close(release["two"])
 
-		expected := []string{errStepFailure.Error(), "three", "four", "five", "six"}
-		verifyStringSlicesEqual(t, expected, actual)
+		for p := range pp {
+			verifyNilErr(t, p.Err)
+		}
+		if order := strings.Join(up.CompletionOrder(), ","); order != "one,three,two,four" {
+			t.Fatalf("expected completion order %q, got %q", "one,three,two,four", order)
+		}
 	})
 
-	t.Run("it panics if called while booting up", func(t *testing.T) {
-		mgr := New("Three-step boot sequence")
-		mgr.Add("one", Sleepop, Noop)
-		mgr.Add("two", Sleepop, Noop)
-		mgr.Add("three", Sleepop, Noop)
+	t.Run("it leaves out services that failed", func(t *testing.T) {
+		mgr := New("Completion Order")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", Errop, Noop)
+		mgr.Add("three", Noop, Noop)
 		i, err := mgr.Sequence("one > two > three")
 		verifyNilErr(t, err)
 
 		up := i.Up(context.Background())
-
-		defer verifyPanicWithMsg(t, panicUp)
-		_ = up.Down(context.Background())
-		t.Fatal("expected to panic")
+		err = up.Wait()
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+		verifyStringSlicesEqual(t, []string{"one"}, up.CompletionOrder())
 	})
 }
 
@@ -453,25 +1247,138 @@ func TestAgent_Cancel(t *testing.T) {
 	})
 }
 
-func TestUnspace(t *testing.T) {
+func TestAgent_ProgressCancel(t *testing.T) {
+	t.Run("it closes the channel without waiting for executing steps", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		started := make(chan struct{})
+
+		mgr := New("Boot it!")
+		mgr.Add("one", Noop, Noop)
+		mgr.Add("two", func() error {
+			close(started)
+			<-release
+			return nil
+		}, Noop)
+		mgr.Add("three", Panicop, Noop)
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		up := i.Up(ctx)
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		done := make(chan Progress)
+		go func() {
+			var last Progress
+			for p := range up.Progress() {
+				time.Sleep(10 * time.Millisecond) // A slow consumer.
+				last = p
+			}
+			done <- last
+		}()
+
+		select {
+		case last := <-done:
+			if last.Service != "two" || last.Err != context.Canceled {
+				t.Fatalf("expected cancellation to be reported for two, got %+v", last)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the progress channel to be closed after cancellation")
+		}
+	})
+}
+
+func TestAgent_WaitCancel(t *testing.T) {
+	t.Run("it returns promptly when the context is cancelled", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Add("one", Sleepop, Noop)
+		mgr.Add("two", Sleepop, Noop)
+		mgr.Add("three", Sleepop, Noop)
+		i, err := mgr.Sequence("one > two > three")
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		up := i.Up(ctx)
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		err = up.Wait()
+		if err != context.Canceled {
+			t.Fatalf("expected error %v, got %v", context.Canceled, err)
+		}
+		if elapsed := time.Since(start); elapsed >= 250*time.Millisecond {
+			t.Fatalf("expected Wait to return before the first step completed, took %s", elapsed)
+		}
+	})
+}
+
+func TestParseFormula_Whitespace(t *testing.T) {
 	cases := map[string]string{
-		"":              "",
-		"one two three": "onetwothree",
-		"one > two":     "one>two",
-		"one	>\n two": "one>two",
-		"one  :two (three)":             "one:two(three)",
-		"one  :two (three > f_o_u_r  )": "one:two(three>f_o_u_r)",
-		"123æøå>>:":                     "123æøå>>:",
+		"one > two":                   "(one>two)",
+		"one\t>\n two":                "(one>two)",
+		" one : two ":                 "(one:two)",
+		"one > ( two : three ) { 2 }": "(one>(two:three){2})",
+		"slow { 5s } > fast":          "(slow{5s}>fast)",
 	}
 
-	var out string
 	for in, expected := range cases {
-		out = unspace(in)
-
-		if out != expected {
-			t.Fatalf("expected unspace(%q) to match %q, got %q", in, expected, out)
+		st, err := parse(in)
+		verifyNilErr(t, err)
+		if actual := st.String(); actual != expected {
+			t.Fatalf("expected parse(%q) to result in %q, got %q", in, expected, actual)
 		}
 	}
+
+	t.Run("it returns an error for whitespace-only formulas", func(t *testing.T) {
+		_, err := parse(" \t\n")
+		verifyParseError(t, err, "empty sequence")
+	})
+
+	t.Run("it returns an error for whitespace inside service names", func(t *testing.T) {
+		_, err := parse("one > two three")
+		verifyParseError(t, err, "whitespace in service name at position 10")
+	})
+
+	t.Run("it reports positions in the original formula", func(t *testing.T) {
+		_, err := parse("a > slow{5x}")
+		verifyParseError(t, err, "invalid timeout: \"5x\" at position 9")
+	})
+
+	// These formulas were stripped of all whitespace before parsing in earlier versions, which joined "one two three"
+	// into the single service name "onetwothree". An empty expectation means that the formula is rejected.
+	t.Run("it no longer joins service names separated by whitespace", func(t *testing.T) {
+		cases := []struct {
+			in, expected string
+			kind         ParseErrorKind
+		}{
+			{"", "", EmptySequence},
+			{"one two three", "", WhitespaceInName},
+			{"one > two", "(one>two)", 0},
+			{"one\t>\n two", "(one>two)", 0},
+			{"one  :two (three)", "(one:twothree)", 0},
+			{"one  :two (three > f_o_u_r  )", "(one:(twothree>f_o_u_r))", 0},
+			{"123æøå>>:", "", InvalidChar},
+		}
+
+		for _, c := range cases {
+			st, err := parse(c.in)
+			if c.expected != "" {
+				verifyNilErr(t, err)
+				if actual := st.String(); actual != c.expected {
+					t.Fatalf("expected parse(%q) to result in %q, got %q", c.in, c.expected, actual)
+				}
+				continue
+			}
+			verifyParseError(t, err, "")
+			if kind := err.(ErrParsingFormula).Kind; kind != c.kind {
+				t.Fatalf("expected parse(%q) to fail with kind %d, got %d", c.in, c.kind, kind)
+			}
+		}
+	})
 }
 
 func TestParseFormula(t *testing.T) {
@@ -526,6 +1433,166 @@ func TestParseFormula(t *testing.T) {
 	})
 }
 
+func TestParseFormula_Timeouts(t *testing.T) {
+	cases := map[string]string{
+		"slow{5s}>fast":         "(slow{5s}>fast)",
+		"slow{1m30s}":           "(slow{1m30s})",
+		"(a{10ms}:b){1}>c{2h}":  "((a{10ms}:b){1}>c{2h0m0s})",
+		"a>(b{250ms}:c{1s}){2}": "(a>(b{250ms}:c{1s}){2})",
+	}
+
+	for in, expected := range cases {
+		st, err := parse(in)
+		verifyNilErr(t, err)
+		if actual := st.String(); actual != expected {
+			t.Fatalf("expected parse(%q) to result in %q, got %q", in, expected, actual)
+		}
+	}
+
+	t.Run("it records the timeout on the step", func(t *testing.T) {
+		st, err := parse("slow{5s}>fast")
+		verifyNilErr(t, err)
+		if st.seq.head.timeout != 5*time.Second || st.seq.tail.timeout != 0 {
+			t.Fatalf("expected timeouts 5s and 0s, got %s and %s", st.seq.head.timeout, st.seq.tail.timeout)
+		}
+	})
+
+	t.Run("it returns an error for invalid timeouts", func(t *testing.T) {
+		_, err := parse("a>slow{5x}")
+		verifyParseError(t, err, "invalid timeout: \"5x\" at position 7")
+		_, err = parse("slow{-1s}")
+		verifyParseError(t, err, "invalid timeout: \"-1s\" at position 5")
+	})
+
+	t.Run("it returns an error for timeouts inside names", func(t *testing.T) {
+		_, err := parse("slow{5s}er")
+		verifyParseError(t, err, "timeout must end a service name at position 8")
+	})
+}
+
+func TestParseFormula_DanglingOperators(t *testing.T) {
+	cases := map[string]string{
+		"one>":       "dangling operator at position 3",
+		">one":       "dangling operator at position 0",
+		"(one>)":     "dangling operator at position 4",
+		"(:one)":     "dangling operator at position 1",
+		"one>>two":   "dangling operator at position 4",
+		"one>(a:b):": "dangling operator at position 9",
+	}
+
+	for in, expected := range cases {
+		_, err := parse(in)
+		verifyParseError(t, err, expected)
+	}
+
+	t.Run("it accepts operators between groups", func(t *testing.T) {
+		_, err := parse("(a:b)>(c:d){1}>e")
+		verifyNilErr(t, err)
+	})
+}
+
+func TestParseFormula_ErrorKinds(t *testing.T) {
+	cases := []struct {
+		in    string
+		kind  ParseErrorKind
+		token string
+		pos   int
+	}{
+		{"  ", EmptySequence, "", -1},
+		{"one)", UnmatchedParen, ")", 3},
+		{"(one", UnmatchedParen, "(", -1},
+		{"(a:b){2", UnmatchedBrace, "{", 5},
+		{"one>>two", DanglingOperator, ">", 4},
+		{"one>t$o", InvalidChar, "$", 5},
+		{"one t", WhitespaceInName, "one", 4},
+		{"one{2}", MisplacedLimit, "2", 3},
+		{"(a:b){x}", InvalidLimit, "x", 5},
+		{"one{5s}x", MisplacedTimeout, "one", 7},
+		{"one{-1s}", InvalidTimeout, "-1s", 4},
+		{"one{ 0s }", InvalidTimeout, "0s", 5},
+		{strings.Repeat("(", 256) + "one" + strings.Repeat(")", 256), NestingTooDeep, "(", 255},
+	}
+
+	for _, c := range cases {
+		_, err := parse(c.in)
+		verifyParseError(t, err, "")
+		pe := err.(ErrParsingFormula)
+		if pe.Kind != c.kind || pe.Token != c.token || pe.Pos != c.pos {
+			t.Fatalf("expected kind %d, token %q and position %d for %q, got %d, %q and %d",
+				c.kind, c.token, c.pos, c.in, pe.Kind, pe.Token, pe.Pos)
+		}
+	}
+
+	t.Run("it reports unknown services", func(t *testing.T) {
+		mgr := New("Error Kinds")
+		mgr.Add("one", Noop, Noop)
+		_, err := mgr.Sequence("one > two")
+		verifyParseError(t, err, "unknown service: \"two\"")
+		pe := err.(ErrParsingFormula)
+		if pe.Kind != UnknownService || pe.Token != "two" {
+			t.Fatalf("expected kind %d and token %q, got %d and %q", UnknownService, "two", pe.Kind, pe.Token)
+		}
+	})
+}
+
+func TestParseFormula_GroupStructure(t *testing.T) {
+	cases := map[string]string{
+		"(one:two)>three":        "((one:two)>three)",
+		"one>(two:three)>four":   "(one>(two:three)>four)",
+		"(one>two):(three>four)": "((one>two):(three>four))",
+		"one:(two>three):four":   "(one:(two>three):four)",
+		"((one:two)>three):four": "(((one:two)>three):four)",
+		"a>(b:c:d){2}>e":         "(a>(b:c:d){2}>e)",
+		"(a:(b:c){1}:d){12}":     "(a:(b:c){1}:d){12}",
+	}
+
+	for in, expected := range cases {
+		st, err := parse(in)
+		verifyNilErr(t, err)
+		if actual := st.String(); actual != expected {
+			t.Fatalf("expected parse(%q) to result in %q, got %q", in, expected, actual)
+		}
+	}
+
+	t.Run("it returns an error for unmatched closing parentheses", func(t *testing.T) {
+		_, err := parse("one)")
+		verifyParseError(t, err, "unmatched parenthesis")
+		_, err = parse("(one:two))>three")
+		verifyParseError(t, err, "unmatched parenthesis")
+	})
+
+	t.Run("it records the concurrency limit on the group", func(t *testing.T) {
+		st, err := parse("a>(b:c:d){2}>e")
+		verifyNilErr(t, err)
+		verifyCountEq(t, uint32(st.seq.head.next.limit), 2)
+		verifyCountEq(t, uint32(st.limit), 0)
+	})
+
+	t.Run("it returns an error for misplaced limits", func(t *testing.T) {
+		_, err := parse("a{2}>b")
+		verifyParseError(t, err, "concurrency limit must follow a group")
+	})
+
+	t.Run("it returns an error for limits on serial groups", func(t *testing.T) {
+		_, err := parse("a:(b>c){2}")
+		verifyParseError(t, err, "concurrency limit must follow a parallel group")
+		_, err = parse("a>(b){1}")
+		verifyParseError(t, err, "concurrency limit must follow a parallel group")
+	})
+
+	t.Run("it returns an error for invalid limits", func(t *testing.T) {
+		_, err := parse("(a:b){0}")
+		verifyParseError(t, err, "invalid concurrency limit: \"0\"")
+		_, err = parse("(a:b){x}")
+		verifyParseError(t, err, "invalid concurrency limit: \"x\"")
+	})
+
+	t.Run("it returns an error for unmatched braces", func(t *testing.T) {
+		_, err := parse("(a:b){2")
+		verifyParseError(t, err, "unmatched brace")
+	})
+}
+
 func TestStepString(t *testing.T) {
 	t.Run("simple case", func(t *testing.T) {
 		st := newStep("aaa")
@@ -643,6 +1710,37 @@ func TestStepString(t *testing.T) {
 	})
 }
 
+func TestAgent_Malformed(t *testing.T) {
+	t.Run("reports an error for an unknown mode", func(t *testing.T) {
+		mgr := New("Malformed boot sequence")
+		mgr.Add("one", Panicop, Noop) // Panicop should never execute.
+		mgr.Add("two", Panicop, Noop)
+		i, err := mgr.Sequence("one > two")
+		verifyNilErr(t, err)
+		i.root.seq.mode = mode('?')
+
+		err = i.Up(context.Background()).Wait()
+		if err != ErrUnknownMode {
+			t.Fatalf("expected error %v, got %v", ErrUnknownMode, err)
+		}
+	})
+
+	t.Run("reports an error for an unknown phase", func(t *testing.T) {
+		mgr := New("Malformed boot sequence")
+		mgr.Add("one", Panicop, Noop) // Panicop should never execute.
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		a := newAgent(i)
+		a.phase = phase(8)
+		a.start(context.Background())
+		err = a.Wait()
+		if err != ErrUnknownPhase {
+			t.Fatalf("expected error %v, got %v", ErrUnknownPhase, err)
+		}
+	})
+}
+
 func TestAgent_Panics(t *testing.T) {
 	t.Run("panics when Agent.Wait() is called after Agent.Progress()", func(t *testing.T) {
 		mgr := New("Single-step boot sequence")
@@ -675,6 +1773,40 @@ func TestAgent_Panics(t *testing.T) {
 	})
 }
 
+func TestAgent_TryCallee(t *testing.T) {
+	t.Run("returns an error when Agent.TryWait() is called after Agent.Progress()", func(t *testing.T) {
+		mgr := New("Single-step boot sequence")
+		mgr.Add("one", Noop, Noop)
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		for range up.Progress() {
+		}
+		err = up.TryWait()
+		if _, ok := err.(CalleeError); !ok {
+			t.Fatalf("expected a CalleeError, got %v", err)
+		}
+	})
+
+	t.Run("returns an error when Agent.TryProgress() is called after Agent.TryWait()", func(t *testing.T) {
+		mgr := New("Single-step boot sequence")
+		mgr.Add("one", Noop, Noop)
+		i, err := mgr.Sequence("one")
+		verifyNilErr(t, err)
+
+		up := i.Up(context.Background())
+		verifyNilErr(t, up.TryWait())
+		prog, err := up.TryProgress()
+		if _, ok := err.(CalleeError); !ok {
+			t.Fatalf("expected a CalleeError, got %v", err)
+		}
+		if prog != nil {
+			t.Fatal("expected a nil channel")
+		}
+	})
+}
+
 func TestProgress(t *testing.T) {
 	t.Run("returns one Progress report per step (simple case)", func(t *testing.T) {
 		mgr := New("One-step boot sequence")
@@ -812,6 +1944,36 @@ func TestProgress(t *testing.T) {
 	})
 }
 
+// serialRunner is a Runner that executes each function immediately, in the
+// order they're passed to Go.
+type serialRunner struct {
+	sync.Mutex
+	calls int
+	err   error
+}
+
+func (r *serialRunner) Go(f func() error) {
+	r.Lock()
+	r.calls++
+	r.Unlock()
+
+	if err := f(); err != nil {
+		r.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.Unlock()
+	}
+}
+
+func (r *serialRunner) Wait() error {
+	r.Lock()
+	defer r.Unlock()
+	err := r.err
+	r.err = nil
+	return err
+}
+
 func newStepPtr(name string) *step {
 	st := newStep(name)
 	return &st
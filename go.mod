@@ -2,4 +2,4 @@ module github.com/mkock/bootseq
 
 go 1.13
 
-require golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+require golang.org/x/sync v0.1.0
@@ -2,12 +2,17 @@ package bootseq
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
 // state represents a Manager's state. It's either:
@@ -22,6 +27,20 @@ const (
 	stateDown
 )
 
+// String returns the name of the state: "idle", "up" or "down".
+func (st state) String() string {
+	switch st {
+	case stateIdle:
+		return "idle"
+	case stateUp:
+		return "up"
+	case stateDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
 // Func is the type used for any function that can be executed as a service in a boot sequence. Any function that you
 // wish to register and execute as a service must satisfy this type.
 type Func func() error
@@ -32,14 +51,194 @@ type Service struct {
 	name     string
 	priority uint16
 	up, down Func
+	promote  Func // Optional; see Manager.RegisterStaged.
+	warmup   Func // Optional; see Service.WithWarmup.
 	after    string
+	meta     map[string]string // Optional; see Service.WithMeta.
+	tags     []string          // Optional; see Service.Tag.
+	retries  int               // Optional; see Service.WithRetry.
+	backoff  time.Duration     // Optional; see Service.WithRetry.
+	timeout  time.Duration     // Optional; see Service.WithTimeout.
+	weight   int               // Optional; see Service.Weight. Zero means a weight of 1.
+	order    int               // Registration order; see Manager.WithStableOrderByRegistration.
+	tx       string            // Optional; see Service.TransactionGroup.
+	solo     bool              // Optional; see Service.Exclusive.
+	mgr      *Manager          // Manager that the Service is registered with, which rejects changes once frozen.
+
+	upState, downState StateFunc   // Bound to the State of each Agent as up and down; see Manager.RegisterStateful.
+	upCtx, downCtx     ContextFunc // Called with the context of the sequence as up and down; see Manager.RegisterContext.
 }
 
 // After sets the receiver Service to be executed after the one defined by the given name.
 func (s *Service) After(name string) {
+	if s.rejected() {
+		return
+	}
 	s.after = name
 }
 
+// WithWarmup sets a function that prepares the receiver Service before the startup sequence begins, such as loading
+// embedded assets or compiling templates. Warmup functions run as a distinct phase before any "up" function, in
+// order of priority, and are never reversed during the shutdown sequence. If a warmup function fails, the startup
+// sequence is aborted before any "up" function runs. WithWarmup returns the receiver to allow for chaining.
+func (s *Service) WithWarmup(fn Func) *Service {
+	if s.rejected() {
+		return s
+	}
+	s.warmup = fn
+	return s
+}
+
+// WithMeta attaches the given key/value pairs to the receiver Service, replacing any attached earlier. They are passed
+// on to progress reports for the Service as Progress.Meta, which is useful for correlating logs, for example by
+// component owner or trace attribute. The map is copied. WithMeta returns the receiver to allow for chaining.
+func (s *Service) WithMeta(kv map[string]string) *Service {
+	if s.rejected() {
+		return s
+	}
+	s.meta = make(map[string]string, len(kv))
+	for k, v := range kv {
+		s.meta[k] = v
+	}
+	return s
+}
+
+// WithRetry makes the startup sequence retry the "up" function of the receiver Service up to the given number of times
+// when it fails, waiting for the given backoff before each retry. The Service is only reported as failed once its final
+// attempt fails. Retries stop early if the context of the sequence is done. See Manager.OnRetry for observing retries.
+// WithRetry returns the receiver to allow for chaining.
+func (s *Service) WithRetry(retries int, backoff time.Duration) *Service {
+	if s.rejected() {
+		return s
+	}
+	s.retries, s.backoff = retries, backoff
+	return s
+}
+
+// WithTimeout limits the time that each call to a Func of the receiver Service may take to the given duration. The
+// limit is derived from the context of the sequence, so a deadline of the context still applies if it expires first,
+// in which case the context error is returned. Otherwise, a call that runs out of time fails with a
+// ServiceTimeoutError, which unwraps to context.DeadlineExceeded. Services registered with Manager.RegisterContext
+// receive a context that is done once the timeout expires, so that they can stop in time. Other Funcs aren't
+// interrupted, and a call that runs out of time continues in the background. Each retry gets a full timeout of its own.
+// WithTimeout returns the receiver to allow for chaining.
+func (s *Service) WithTimeout(d time.Duration) *Service {
+	if s.rejected() {
+		return s
+	}
+	s.timeout = d
+	return s
+}
+
+// Weight sets the number of slots that the receiver Service takes up of the limit set with Manager.WithMaxConcurrency,
+// for Services that are heavier than others. For example, with a limit of 10, a Service with a weight of 5 can run
+// alongside two Services with a weight of 2, but not alongside another Service with a weight of 5, which waits. The
+// default weight is 1, and weights of less than 1 are replaced by 1. A weight greater than the limit makes the
+// Service run on its own. Weights have no effect without a limit, or with a GroupRunner, which is responsible for
+// scheduling the tasks it receives itself. Weight returns the receiver to allow for chaining.
+func (s *Service) Weight(w int) *Service {
+	if s.rejected() {
+		return s
+	}
+	s.weight = max(w, 1)
+	return s
+}
+
+// Tag adds the given tags to the receiver Service, such as "network" or "storage", which allows for running a subset
+// of the registered Services with Manager.AgentForTags. Tag returns the receiver to allow for chaining.
+func (s *Service) Tag(tags ...string) *Service {
+	if s.rejected() {
+		return s
+	}
+	for _, tag := range tags {
+		if !s.hasTag(tag) {
+			s.tags = append(s.tags, tag)
+		}
+	}
+	return s
+}
+
+// TransactionGroup adds the receiver Service to the transaction group with the given name. Services in a transaction
+// group come up as a unit: if one of them fails during the startup sequence, the members that have already come up
+// are rolled back by running their "down" functions, in reverse order of priority, before the startup sequence
+// returns the error. Services outside the transaction group are left as they are. TransactionGroup returns the
+// receiver to allow for chaining.
+func (s *Service) TransactionGroup(name string) *Service {
+	if s.rejected() {
+		return s
+	}
+	s.tx = name
+	return s
+}
+
+// Exclusive makes the receiver Service run on its own, such as a migration that needs exclusive access to the whole
+// system. The Service gets a priority group to itself, so the priority groups before it have completed, and the ones
+// after it haven't started, while it runs. It runs before the other Services that it would otherwise share a priority
+// group with. Two exclusive Services can't be placed at the same priority, which fails validation with an
+// ExclusiveConflictError. Exclusive returns the receiver to allow for chaining.
+func (s *Service) Exclusive() *Service {
+	if s.rejected() {
+		return s
+	}
+	s.solo = true
+	return s
+}
+
+// Name returns the name of the Service.
+func (s *Service) Name() string {
+	return s.name
+}
+
+// Predecessor returns the name of the Service that the Service comes after, as set with After, or an empty string if
+// it doesn't come after another Service.
+func (s *Service) Predecessor() string {
+	return s.after
+}
+
+// Priority returns the priority of the Service in the boot sequence, starting from 1, or 0 if it hasn't been
+// resolved, such as for Services returned by Manager.All when the Manager doesn't validate.
+func (s *Service) Priority() uint16 {
+	return s.priority
+}
+
+// Tags returns a copy of the tags of the Service; see Service.Tag.
+func (s *Service) Tags() []string {
+	return append([]string(nil), s.tags...)
+}
+
+// rejected returns true if the Manager that the Service is registered with is frozen, in which case a change to the
+// Service is rejected like a registration; see Manager.Freeze.
+func (s *Service) rejected() bool {
+	if s.mgr == nil {
+		return false
+	}
+	s.mgr.lock.Lock()
+	defer s.mgr.lock.Unlock()
+
+	return s.mgr.reject(s.name)
+}
+
+// clone returns a copy of the Service that shares no maps or slices with it.
+func (s *Service) clone() *Service {
+	srvc := *s
+	srvc.meta = make(map[string]string, len(s.meta))
+	for k, v := range s.meta {
+		srvc.meta[k] = v
+	}
+	srvc.tags = append([]string(nil), s.tags...)
+	return &srvc
+}
+
+// hasTag returns true if the Service has been tagged with the given tag.
+func (s *Service) hasTag(tag string) bool {
+	for _, t := range s.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // byState returns the service function that matches the provided state.
 // It panics if the state is unknown.
 func (s *Service) byState(ph state) Func {
@@ -53,14 +252,128 @@ func (s *Service) byState(ph state) Func {
 	}
 }
 
+// DoneService is the Service name of the final Progress reported for a sequence that completed or was interrupted by
+// cancellation or a halt. When a Service fails, the sequence ends with that Service's report instead. DoneService never
+// refers to an executed Service.
+const DoneService = "<done>"
+
 // Progress is the boot sequence feedback medium.
 // Progress is communicated on channels returned by methods Up() and Down() and provides feedback on the current
 // progress of the boot sequence. This includes the name of the Service that was last executed, along with an optional
 // error if the Service Func failed. Err will be nil on success.
+// The last Progress reported for a sequence has its Service set to DoneService, unless a Service failed.
 // Progress satisfies the error interface.
 type Progress struct {
-	Service string
-	Err     error
+	Sequence string // Name of the boot sequence, which tells reports from several Agents apart.
+	Service  string
+	Err      error
+	Kind     Kind              // Classifies Err; NoError when Err is nil.
+	Meta     map[string]string // Attached with Service.WithMeta; nil for DoneService. Must not be modified.
+
+	Remaining int  // Number of Services in the sequence that haven't completed yet, not counting this one.
+	Skipped   bool // Was the Service skipped, as another member of its group was picked? See Manager.OneOf.
+}
+
+// Kind classifies the error carried by a Progress report, allowing observers to tell failures during startup apart
+// from failures during shutdown and rollback.
+type Kind uint8
+
+const (
+	// NoError is the Kind of Progress reports without an error.
+	NoError Kind = iota
+
+	// UpError is the Kind of errors returned during the startup sequence.
+	UpError
+
+	// DownError is the Kind of errors returned during the shutdown sequence.
+	DownError
+
+	// RollbackError is the Kind of errors returned by down Funcs that run in order to roll back Services that came up
+	// during a startup sequence that failed.
+	RollbackError
+
+	// WarmupError is the Kind of errors returned by warmup Funcs before the startup sequence.
+	WarmupError
+)
+
+// String returns a short description of the Kind.
+func (k Kind) String() string {
+	switch k {
+	case NoError:
+		return "no error"
+	case UpError:
+		return "up error"
+	case DownError:
+		return "down error"
+	case RollbackError:
+		return "rollback error"
+	case WarmupError:
+		return "warmup error"
+	default:
+		return "unknown error"
+	}
+}
+
+// StopReason classifies the outcome of the most recent startup or shutdown sequence run by an Agent, allowing observers
+// to bucket runs by outcome without inspecting the returned error.
+type StopReason uint8
+
+const (
+	// NotStopped is the StopReason of Agents that haven't run a sequence yet, or whose sequence is still in progress.
+	NotStopped StopReason = iota
+
+	// StoppedComplete is the StopReason of sequences that ran all Services without error.
+	StoppedComplete
+
+	// StoppedServiceError is the StopReason of sequences that stopped because a Service Func returned an error.
+	StoppedServiceError
+
+	// StoppedCancelled is the StopReason of sequences that stopped because their context was cancelled, or because
+	// a progress function halted them.
+	StoppedCancelled
+
+	// StoppedTimeout is the StopReason of sequences that stopped because their context, or the context of one of
+	// their priority groups, exceeded its deadline.
+	StoppedTimeout
+)
+
+// String returns a short description of the StopReason.
+func (r StopReason) String() string {
+	switch r {
+	case NotStopped:
+		return "not stopped"
+	case StoppedComplete:
+		return "complete"
+	case StoppedServiceError:
+		return "service error"
+	case StoppedCancelled:
+		return "cancelled"
+	case StoppedTimeout:
+		return "timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// stopReasonOf returns the StopReason for a sequence that stopped because ctx is done.
+func stopReasonOf(ctx context.Context) StopReason {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return StoppedTimeout
+	}
+	return StoppedCancelled
+}
+
+// Runner runs Service Funcs concurrently and waits for them to finish.
+// Wait returns the first non-nil error returned by any of the functions passed to Go. *errgroup.Group satisfies this
+// interface and is used by default.
+type Runner interface {
+	Go(f func() error)
+	Wait() error
+}
+
+// newErrgroupRunner returns the default Runner, backed by an errgroup.
+func newErrgroupRunner() Runner {
+	return &errgroup.Group{}
 }
 
 // unorderedServices represents a collection of Services before they've been ordered.
@@ -74,8 +387,209 @@ type orderedServices map[uint16][]Service
 type Manager struct {
 	name string
 
-	lock     sync.Mutex // Protects field services.
-	services unorderedServices
+	lock         sync.Mutex // Protects the fields below.
+	services     unorderedServices
+	opts         options
+	resolveAfter func(name string, names []string) []string // Optional; see Manager.ResolveAfter.
+	limit        int                                        // Max. number of Services; see Manager.WithServiceLimit.
+	frozen       bool                                       // Are registrations rejected? See Manager.Freeze.
+	sealOnAgent  bool                                       // Is the Manager frozen by Agent? See Manager.SealOnAgent.
+	sealed       error                                      // First change rejected while frozen; see Manager.reject.
+	placeholders map[string]bool                            // Services without functions yet; see LoadManager.
+}
+
+// GroupRunner runs the tasks of a single priority group and waits for them to finish. Unlike a Runner, a GroupRunner
+// receives all tasks of the group at once, which allows for custom scheduling. Run must return an error if any one of
+// the tasks failed.
+type GroupRunner interface {
+	Run(ctx context.Context, tasks []func() error) error
+}
+
+// limiter is implemented by Runners that can cap the number of functions running at the same time, such as
+// *errgroup.Group.
+type limiter interface {
+	SetLimit(n int)
+}
+
+// runnerGroup adapts a function that creates Runners to the GroupRunner interface. A limit greater than zero is
+// applied to Runners that implement limiter.
+type runnerGroup struct {
+	newRunner func() Runner
+	limit     int
+}
+
+// Run runs the given tasks using a new Runner.
+func (r runnerGroup) Run(_ context.Context, tasks []func() error) error {
+	grp := r.newRunner()
+	if l, ok := grp.(limiter); ok && r.limit > 0 {
+		l.SetLimit(r.limit)
+	}
+	for _, task := range tasks {
+		grp.Go(task)
+	}
+	return grp.Wait()
+}
+
+// weightedGroup is a GroupRunner that limits the total weight of the tasks running at the same time, using a
+// weighted semaphore rather than the limit of the Runner; see Service.Weight.
+type weightedGroup struct {
+	newRunner func() Runner
+	limit     int
+	weights   []int // One per task.
+}
+
+// Run runs the given tasks using a new Runner, starting each one in order once its weight fits within the limit.
+func (r weightedGroup) Run(_ context.Context, tasks []func() error) error {
+	grp := r.newRunner()
+	sem := semaphore.NewWeighted(int64(r.limit))
+	for i, task := range tasks {
+		task, weight := task, int64(min(r.weights[i], r.limit))
+		_ = sem.Acquire(context.Background(), weight) // Never fails, as the context is never done.
+		grp.Go(func() error {
+			defer sem.Release(weight)
+			return task()
+		})
+	}
+	return grp.Wait()
+}
+
+// serialGroup is a GroupRunner that runs tasks one at a time in the calling goroutine, in the order given.
+type serialGroup struct{}
+
+// Run runs every one of the given tasks and returns the first error, if any, just like a Runner would.
+func (serialGroup) Run(_ context.Context, tasks []func() error) error {
+	var first error
+	for _, task := range tasks {
+		if err := task(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// options contains the settings that a Manager passes on to each Agent it instantiates.
+type options struct {
+	newRunner   func() Runner                             // Creates a Runner for each priority group.
+	groupRunner GroupRunner                               // Runs each priority group; takes precedence over newRunner.
+	beforeEach  func(name string, phase string)           // Called before each Service Func.
+	maxParallel int                                       // Max. Service Funcs running at once; 0 means no limit.
+	bestEffort  bool                                      // Does the shutdown continue past failed priority groups?
+	onRetry     func(name string, attempt int, err error) // Called before each retry of a Service Func.
+	errorFilter func(name string, err error) error        // Decides whether errors of Service Funcs are fatal.
+	byRegister  bool                                      // Are Services of a group ordered by registration?
+	sortGroups  bool                                      // Are Services of a group always run in sorted order?
+	choices     []choice                                  // Groups of interchangeable Services; see Manager.OneOf.
+	finalizers  []finalizer                               // Run at the end of each shutdown sequence.
+	grace       time.Duration                             // Max. wait for running Services once cancelled; 0 is no limit.
+	strategy    Strategy                                  // Orders Services into priority groups; nil is MaxParallel.
+	upTimeout   time.Duration                             // Max. duration of startup sequences; 0 means no limit.
+	downTimeout time.Duration                             // Max. duration of shutdown sequences; 0 means no limit.
+}
+
+// maxServices is the number of Services that a Manager can contain at most.
+const maxServices = 65535
+
+// group returns the GroupRunner to use for running priority groups.
+func (o options) group() GroupRunner {
+	if o.isSerial() {
+		return serialGroup{}
+	}
+	if o.groupRunner != nil {
+		return o.groupRunner
+	}
+	return runnerGroup{newRunner: o.newRunner, limit: o.maxParallel}
+}
+
+// timeoutContext returns a copy of ctx that times out after d, unless d is zero or less, in which case ctx is returned
+// as is. If ctx has an earlier deadline, that deadline applies.
+func timeoutContext(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// weighted returns the GroupRunner to use for running tasks with the given weights, one per task. A weightedGroup is
+// used if any of the weights differ from 1, unless Services run one at a time, or there is no limit or a GroupRunner.
+func (o options) weighted(weights []int) GroupRunner {
+	if o.isSerial() || o.groupRunner != nil || o.maxParallel == 0 {
+		return o.group()
+	}
+	for _, weight := range weights {
+		if weight != 1 {
+			return weightedGroup{newRunner: o.newRunner, limit: o.maxParallel, weights: weights}
+		}
+	}
+	return o.group()
+}
+
+// order orders the given Services into priority groups using the configured Strategy.
+func (o options) order(u unorderedServices) orderedServices {
+	if o.strategy == nil {
+		return MaxParallel.order(u, o)
+	}
+	return o.strategy.order(u, o)
+}
+
+// Strategy decides how the Services of a Manager are ordered into priority groups; see Manager.WithStrategy. The
+// package provides the strategies MaxParallel and Serial. Strategy can't be implemented outside the package.
+type Strategy interface {
+	order(u unorderedServices, opts options) orderedServices
+}
+
+// maxParallel is the Strategy behind MaxParallel.
+type maxParallel struct{}
+
+// order orders the given Services by priority; see unorderedServices.order.
+func (maxParallel) order(u unorderedServices, _ options) orderedServices {
+	return u.order()
+}
+
+// serial is the Strategy behind Serial.
+type serial struct{}
+
+// order orders the given Services by priority like maxParallel, and then gives each Service a priority group of its
+// own, sorting the Services of each original group; see options.less.
+func (serial) order(u unorderedServices, opts options) orderedServices {
+	groups := u.order()
+	ordered := make(orderedServices, len(u))
+	next := uint16(0)
+	for priority := uint16(1); priority <= uint16(len(groups)); priority++ {
+		services := groups[priority]
+		sort.Slice(services, func(i, j int) bool {
+			return opts.less(services[i], services[j])
+		})
+		for _, service := range services {
+			next++
+			service.priority = next
+			ordered[next] = []Service{service}
+		}
+	}
+	return ordered
+}
+
+var (
+	// MaxParallel is the default Strategy. It places each Service in the earliest priority group that it can run in,
+	// which lets as many Services as possible run concurrently, and keeps the boot sequence short.
+	MaxParallel Strategy = maxParallel{}
+
+	// Serial is a Strategy that places each Service in a priority group of its own, in an order that satisfies every
+	// dependency, so that Services run one at a time. This trades a longer boot sequence for a lower peak load.
+	Serial Strategy = serial{}
+)
+
+// less reports whether Service s comes before Service t within a priority group: in order of registration if
+// configured with Manager.WithStableOrderByRegistration, and alphabetically otherwise.
+func (o options) less(s, t Service) bool {
+	if o.byRegister {
+		return s.order < t.order
+	}
+	return s.name < t.name
+}
+
+// isSerial returns true if Service Funcs run one at a time.
+func (o options) isSerial() bool {
+	return o.maxParallel == 1
 }
 
 // Agent represents the execution of a sequence of Services. For any sequence, there will be two agents in play: one for
@@ -84,12 +598,37 @@ type Manager struct {
 // Each Agent keeps track of its progress and handles execution of sequence Services.
 type Agent struct {
 	name            string          // Name of boot sequence.
-	progressFn      func(Progress)  // Progress reporting.
+	st              *State          // Values shared between Service Funcs; see Manager.RegisterStateful.
+	readyAt         uint16          // Priority group after which ready is closed; see Agent.UpReadyOn.
+	levelTimeout    time.Duration   // Time allotted to each priority group; see Agent.UpWithLevelTimeout.
+	downLock        sync.Mutex      // Serialises calls to Agent.DownOnce.
+	downRan         bool            // Has Agent.DownOnce started the shutdown sequence?
+	downErr         error           // Result of the shutdown sequence run by Agent.DownOnce.
+	ready           chan struct{}   // Closed once the readyAt group has come up.
 	orderedServices orderedServices // Map of Service priorities, with each  containing a slice of services.
+	opts            options         // Settings inherited from the Manager.
+
+	lock    sync.Mutex // Controls access to the fields below it.
+	state   state      // Current state: up/down.
+	forward bool       // Does the shutdown sequence run in the order of the startup sequence?
+	isDone  bool       // Did sequence execution complete?
+
+	failures map[string]error         // Errors by Service name for the most recent run.
+	started  map[string]bool          // Names of the Services brought up by the startup sequence.
+	upOK     map[string]bool          // Names of the Services whose "up" Func succeeded; see Agent.Completed.
+	timings  map[string]time.Duration // Time spent by each Service during the most recent run.
+	spans    map[string][2]time.Time  // Start and end of each Service during the most recent run.
+	groups   map[state][]string       // Services run by each priority group, by direction; see Agent.VerifyReverse.
+	runStart time.Time                // Start of the most recent run.
+	runEnd   time.Time                // End of the most recent run; zero while in progress.
+	stopped  StopReason               // Outcome of the most recent run; NotStopped while in progress.
+	progress *reporter                // Reports the Progress of the current run; see reporter.
+
+	injections map[string]injection // Failures injected by Service name; see Agent.InjectFailure.
+	skipped    map[string]bool      // Services not picked during the current run; see Manager.OneOf.
 
-	lock   sync.Mutex // Controls access to the fields below it.
-	state  state      // Current state: up/down.
-	isDone bool       // Did sequence execution complete?
+	onUpComplete   func(error) // Called once the startup sequence has completed; see Agent.OnUpComplete.
+	onDownComplete func(error) // Called once the shutdown sequence has completed; see Agent.OnDownComplete.
 }
 
 // setPriority looks up the Service with the given name and attempts to set its priority.
@@ -120,6 +659,7 @@ func (u unorderedServices) setPriority(name string) uint16 {
 // 2. Services that come immediately after another, receive an order that is one higher than the other.
 // 3. If a service refers to another which is unordered, a depth-first approach is taken to resolve the orders
 //    of each one.
+// Exclusive Services are then moved to priority groups of their own; see orderedServices.isolate.
 // order assumes that each referenced service exists.
 func (u unorderedServices) order() orderedServices {
 	ordered := make(orderedServices, len(u))
@@ -136,7 +676,67 @@ func (u unorderedServices) order() orderedServices {
 		ordered[priority] = append(ordered[priority], *service)
 	}
 
-	return ordered
+	return ordered.isolate()
+}
+
+// validateExclusive checks that no two exclusive Services are placed at the same priority; see Service.Exclusive.
+// It assumes that the Services have been validated.
+func (u unorderedServices) validateExclusive() error {
+	solo := make(map[uint16]string)
+	for name, srvc := range u {
+		if !srvc.solo {
+			continue
+		}
+		priority := u.setPriority(name)
+		if other, ok := solo[priority]; ok {
+			pair := []string{other, name}
+			sort.Strings(pair)
+			return ExclusiveConflictError(strings.Join(pair, ", "))
+		}
+		solo[priority] = name
+	}
+
+	return nil
+}
+
+// isolate returns the priority groups with each exclusive Service moved to a priority group of its own, ahead of the
+// rest of its original group. The priorities of the following groups are shifted accordingly. isolate returns the
+// receiver if it contains no exclusive Services.
+func (o orderedServices) isolate() orderedServices {
+	solo := false
+	for _, services := range o {
+		for _, service := range services {
+			solo = solo || service.solo
+		}
+	}
+	if !solo {
+		return o
+	}
+
+	isolated := make(orderedServices, len(o))
+	next := uint16(0)
+	place := func(services []Service) {
+		next++
+		for _, service := range services {
+			service.priority = next
+			isolated[next] = append(isolated[next], service)
+		}
+	}
+	for priority := uint16(1); priority <= uint16(len(o)); priority++ {
+		var rest []Service
+		for _, service := range o[priority] {
+			if service.solo {
+				place([]Service{service})
+			} else {
+				rest = append(rest, service)
+			}
+		}
+		if len(rest) > 0 {
+			place(rest)
+		}
+	}
+
+	return isolated
 }
 
 // length returns the total number of registered Services.
@@ -150,134 +750,1107 @@ func (o orderedServices) length() int {
 	return length
 }
 
-// New returns a new and uninitialised boot sequence Manager.
+// New returns a new and uninitialised boot sequence Manager. The name identifies the boot sequence, such as in logs
+// when running several of them, and must not be empty: an empty name fails validation with ErrEmptyName.
 func New(name string) *Manager {
 	services := make(map[string]*Service)
-	mgr := Manager{lock: sync.Mutex{}, name: name, services: services}
+	mgr := Manager{
+		lock:     sync.Mutex{},
+		name:     name,
+		services: services,
+		opts:     options{newRunner: newErrgroupRunner},
+		limit:    maxServices,
+	}
 	return &mgr
 }
 
-// Register registers a single named Service to the boot sequence, with the given "up" and "down" functions. If a
-// Service with the given name already exists, the provided up- and down functions replace those already registered. Add
-// returns a pointer to the added Service, that you can call After() on, in order to influence order of execution.
-func (m *Manager) Register(name string, up, down Func) *Service {
+// WithGroupRunner sets the GroupRunner that runs the Service Funcs of each priority group that an Agent executes. This
+// allows for custom scheduling, bounded pools or deterministic runners during testing. A GroupRunner takes precedence
+// over any function set by WithRunner, and a nil GroupRunner restores the default behaviour. WithGroupRunner only
+// affects Agents that are instantiated after the call. It returns the Manager to allow for chaining.
+func (m *Manager) WithGroupRunner(r GroupRunner) *Manager {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	if len(m.services) == 65535 {
-		panic(panicServiceLimit)
+	if m.reject("WithGroupRunner") {
+		return m
 	}
-
-	ref := &Service{name, 0, up, down, ""}
-	m.services[name] = ref
-	return ref
+	m.opts.groupRunner = r
+	return m
 }
 
-// ServiceCount returns the number of services currently registered with the
-// Manager.
-func (m *Manager) ServiceCount() uint16 {
+// WithMaxConcurrency limits the number of Service Funcs that may run at the same time within a priority group to n. A
+// limit of zero or less means no limit, which is the default. The limit is applied to Runners that have a SetLimit
+// method, such as the default one, while a GroupRunner is responsible for scheduling the tasks it receives itself.
+// With a limit of 1, no Runner or GroupRunner is used at all: the Services in each priority group run one at a time,
+// in alphabetical order, or in order of registration if configured with Manager.WithStableOrderByRegistration, which
+// makes the order of execution deterministic.
+// WithMaxConcurrency only affects Agents that are instantiated after the call. It returns the Manager to allow for
+// chaining.
+func (m *Manager) WithMaxConcurrency(n int) *Manager {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	return uint16(len(m.services))
+	if m.reject("WithMaxConcurrency") {
+		return m
+	}
+	if n < 0 {
+		n = 0
+	}
+	m.opts.maxParallel = n
+	return m
 }
 
-// ServiceNames returns the name of each registered service, in no
-// particular order.
-func (m *Manager) ServiceNames() []string {
+// WithBestEffortDown makes the shutdown sequence of the Manager's Agents continue with the remaining priority groups
+// when a "down" Func fails, rather than stopping at the failed group, in order to avoid leaking the resources held by
+// the Services that were brought up. The errors of all failed Services are returned as a MultiError once the sequence
+// has completed. If a priority group fails without any failed Services, such as when a GroupRunner returns an error
+// of its own, and no Service fails in the other groups either, the error of the last failed group is returned instead.
+// Cancellation still stops the sequence between priority groups. The startup sequence is unaffected.
+// WithBestEffortDown only affects Agents that are instantiated after the call. It returns the Manager to allow for
+// chaining.
+func (m *Manager) WithBestEffortDown(enabled bool) *Manager {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ns := make([]string, 0, len(m.services))
-
-	for name := range m.services {
-		ns = append(ns, name)
+	if m.reject("WithBestEffortDown") {
+		return m
 	}
-
-	return ns
+	m.opts.bestEffort = enabled
+	return m
 }
 
-// Agent orders the registered services by priority and returns an Agent for controlling the startup and shutdown
-// sequences. Agent returns an error if any of the registered Services refer to other Services that are not registered.
-func (m *Manager) Agent() (agent *Agent, err error) {
+// WithCancelGrace limits the time that the Manager's Agents wait for the Services of the current priority group to
+// finish once the sequence has been cancelled, or has timed out. Without a grace period, the Agent waits for them
+// indefinitely. When the grace period expires, the Services that are still running are abandoned: they keep running in
+// the background, and may still be reported, but the sequence returns right away. The returned error then joins the
+// cause of the cancellation with an AbandonedServicesError naming the abandoned Services. Abandoned Services are never
+// marked as brought up, and their errors and timings are left out of LastRunFailures and Timeline.
+// WithCancelGrace only affects Agents that are instantiated after the call. It returns the Manager to allow for
+// chaining.
+func (m *Manager) WithCancelGrace(d time.Duration) *Manager {
 	m.lock.Lock()
-	if len(m.services) == 0 {
-		err = EmptySequenceError(m.name)
-		return
-	}
-	m.lock.Unlock()
-	if err = m.Validate(); err != nil {
-		return
+	defer m.lock.Unlock()
+
+	if m.reject("WithCancelGrace") {
+		return m
 	}
-	agent = &Agent{}
-	agent.name = m.name
-	agent.orderedServices = m.services.order()
-	return
+	m.opts.grace = d
+	return m
 }
 
-// Validate cycles through each registered service and checks if they refer to other service names that don't exist,
-// or if they refer to themselves. Validate returns an error if this is the case, or nil otherwise.
-func (m *Manager) Validate() error {
+// WithStrategy sets the Strategy that the Manager uses for ordering Services into priority groups, such as Serial for
+// running Services one at a time without changing their dependencies. The default Strategy is MaxParallel. A nil
+// Strategy restores the default.
+// WithStrategy only affects Agents that are instantiated after the call. It returns the Manager to allow for chaining.
+func (m *Manager) WithStrategy(s Strategy) *Manager {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	if len(m.services) == 0 {
-		return EmptySequenceError(m.name)
+	if m.reject("WithStrategy") {
+		return m
 	}
+	m.opts.strategy = s
+	return m
+}
 
-	for name, srvc := range m.services {
-		if srvc.up == nil || srvc.down == nil {
-			return NilFuncError(srvc.name)
-		}
-		if srvc.after == "" {
-			continue
-		}
-		if srvc.after == name {
-			return SelfReferenceError(srvc.after)
-		}
-		prev, ok := m.services[srvc.after]
-		if ok {
-			if prev.after == srvc.name {
-				return CyclicReferenceError(srvc.name)
-			}
-		} else {
-			return UnregisteredServiceError(srvc.after)
-		}
+// WithStartupTimeout limits the duration of each startup sequence run by the Manager's Agents to d, as though the
+// context passed to Agent.Up had a timeout of d. If the context already has a deadline, the earlier one applies. A
+// duration of zero or less removes the limit, which is the default.
+// WithStartupTimeout only affects Agents that are instantiated after the call. It returns the Manager to allow for
+// chaining.
+func (m *Manager) WithStartupTimeout(d time.Duration) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("WithStartupTimeout") {
+		return m
 	}
+	m.opts.upTimeout = d
+	return m
+}
 
-	return nil
+// WithShutdownTimeout limits the duration of each shutdown sequence run by the Manager's Agents to d, like
+// WithStartupTimeout does for startup sequences. This allows for a policy such as "boot within a minute, but shut down
+// within ten seconds" without preparing a context for each sequence.
+// WithShutdownTimeout only affects Agents that are instantiated after the call. It returns the Manager to allow for
+// chaining.
+func (m *Manager) WithShutdownTimeout(d time.Duration) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("WithShutdownTimeout") {
+		return m
+	}
+	m.opts.downTimeout = d
+	return m
 }
 
-// ServiceCount returns the number of services currently registered with the Agent.
-func (a *Agent) ServiceCount() uint16 {
-	return uint16(a.orderedServices.length())
+// WithStableOrderByRegistration makes the Manager's Agents order the Services within each priority group by the order
+// in which they were registered, rather than alphabetically, which is the default. The ordering applies to the plan
+// rendered by Agent.String and Agent.Linearize, to the other methods that list the Services of a group, and to the
+// order of execution when Services run one at a time. Replacing a registered Service keeps its original position.
+// WithStableOrderByRegistration only affects Agents that are instantiated after the call. It returns the Manager to
+// allow for chaining.
+func (m *Manager) WithStableOrderByRegistration() *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("WithStableOrderByRegistration") {
+		return m
+	}
+	m.opts.byRegister = true
+	return m
 }
 
-// String returns a string representation of the registered Services ordered by priority.
-// Service names are wrapped in parentheses, and separated by a colon when it might run concurrently with one or more
-// other services, and a right-arrow when it will run before another service.
-// Services that have the same priority are sorted alphabetically for reasons of reproducibility.
-func (a *Agent) String() string {
-	var sequence strings.Builder
+// WithDeterministicWithinLevel makes the Manager's Agents hand the Services of each priority group to the GroupRunner
+// in sorted order, alphabetically or by registration (see Manager.WithStableOrderByRegistration), rather than in an
+// arbitrary order, which is the default. This makes the order in which Services are started reproducible, such as for
+// tests that assert the order of progress reports. Services that run concurrently may still complete in any order,
+// unless configured with Manager.WithMaxConcurrency(1).
+// WithDeterministicWithinLevel only affects Agents that are instantiated after the call. It returns the Manager to
+// allow for chaining.
+func (m *Manager) WithDeterministicWithinLevel() *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 
-	for i := uint16(1); i <= uint16(len(a.orderedServices)); i++ {
-		names := make([]string, len(a.orderedServices[i]))
-		for j, service := range a.orderedServices[i] {
-			names[j] = service.name
-		}
-		if len(names) > 1 {
-			sort.Strings(names)
-		}
-		sequence.WriteString("(" + strings.Join(names, " : ") + ") > ")
+	if m.reject("WithDeterministicWithinLevel") {
+		return m
 	}
+	m.opts.sortGroups = true
+	return m
+}
 
-	ret := sequence.String()
-	return ret[:len(ret)-3]
+// WithServiceLimit limits the number of Services that may be registered to n, which is useful as a guardrail against
+// runaway registrations. Registering a Service beyond the limit is a panic, but replacing a registered Service is
+// always allowed. Limits outside of the range 1-65535 are replaced by 65535, which is the default. It returns the
+// Manager to allow for chaining.
+func (m *Manager) WithServiceLimit(n int) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("WithServiceLimit") {
+		return m
+	}
+	if n < 1 || n > maxServices {
+		n = maxServices
+	}
+	m.limit = n
+	return m
 }
 
-// Up runs the startup sequence.
-// Up returns an error if the Agent's current state doesn't allow the sequence to start.
+// WithRunner sets the function used for creating a new Runner for each priority group that an Agent executes. This
+// allows for integration with worker pools or deterministic runners during testing. WithRunner only affects Agents
+// that are instantiated after the call. It returns the Manager to allow for chaining.
+func (m *Manager) WithRunner(fn func() Runner) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("WithRunner") {
+		return m
+	}
+	m.opts.newRunner = fn
+	return m
+}
+
+// BeforeEach sets a function to call immediately before each Service Func is executed, during both the startup and
+// the shutdown sequence. It receives the name of the Service and the phase, "up" or "down". Together with progress
+// reports, this allows for observing both the start and the end of each Service Func.
+// Services in the same priority group run concurrently, so fn must be safe for concurrent use.
+// BeforeEach only affects Agents that are instantiated after the call. It returns the Manager to allow for chaining.
+func (m *Manager) BeforeEach(fn func(name string, phase string)) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("BeforeEach") {
+		return m
+	}
+	m.opts.beforeEach = fn
+	return m
+}
+
+// WithErrorFilter sets a function that decides which errors returned by Service Funcs are fatal, during both the
+// startup and the shutdown sequence. It's called with the name of the Service and the error whenever a Service Func
+// fails. If fn returns nil, the Service is considered successful: the original error is still reported, but the
+// sequence continues. Otherwise, the returned error, which may wrap the original one, is reported and stops the
+// sequence as usual. Errors caused by cancellation or timeouts are never passed to fn.
+// Services in the same priority group run concurrently, so fn must be safe for concurrent use.
+// WithErrorFilter only affects Agents that are instantiated after the call. It returns the Manager to allow for
+// chaining.
+func (m *Manager) WithErrorFilter(fn func(name string, err error) error) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("WithErrorFilter") {
+		return m
+	}
+	m.opts.errorFilter = fn
+	return m
+}
+
+// OnRetry sets a function to call before each retry of a Service configured with Service.WithRetry, once the previous
+// attempt has failed and before the backoff. It receives the name of the Service, the number of the retry that is about
+// to be made, starting from 1, and the error returned by the previous attempt. Unlike progress reports, which only
+// report the final outcome, this allows for observing flapping Services.
+// Services in the same priority group run concurrently, so fn must be safe for concurrent use.
+// OnRetry only affects Agents that are instantiated after the call. It returns the Manager to allow for chaining.
+func (m *Manager) OnRetry(fn func(name string, attempt int, err error)) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("OnRetry") {
+		return m
+	}
+	m.opts.onRetry = fn
+	return m
+}
+
+// ResolveAfter sets a function for computing the Service that each Service comes after, right before the Services
+// are ordered. This allows for late binding of dependencies in plugin architectures, where not all Services are known
+// at the time of registration. fn is called once for each registered Service by Validate and Agent, with the name of
+// the Service and the names of all registered Services, sorted alphabetically. It returns the names of the Services
+// that the Service comes after, which replace the one set by Service.After. An empty result means that the Service
+// doesn't come after any other, and a result with more than one name is an error, as a Service can only come after
+// one other Service. The resolved references are validated like any other.
+// fn is called while the Manager is locked, so it must not call any methods on the Manager. The registered Services
+// are left untouched. ResolveAfter returns the Manager to allow for chaining.
+func (m *Manager) ResolveAfter(fn func(name string, names []string) []string) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject("ResolveAfter") {
+		return m
+	}
+	m.resolveAfter = fn
+	return m
+}
+
+// Register registers a single named Service to the boot sequence, with the given "up" and "down" functions. If a
+// Service with the given name already exists, it's replaced by the new one, including the Service that it comes after
+// and any other settings, such as tags. Only its position in the order of registration is kept; see
+// Manager.WithStableOrderByRegistration. Services loaded by LoadManager are the exception: registering one of them
+// for the first time fills in its functions, and keeps the order of execution from the plan. Register returns a
+// pointer to the added Service, that you can call After() on, in order to influence order of execution. A frozen
+// Manager registers nothing, and returns the error from Validate and Agent instead; see Manager.Freeze.
+func (m *Manager) Register(name string, up, down Func) *Service {
+	return m.register(Service{name: name, up: up, down: down})
+}
+
+// RegisterStateful registers a single named Service like Register, but with functions that receive the State of the
+// Agent running them. The State is kept by the Agent from the startup sequence through to the shutdown sequence, so
+// "up" can store a handle to a resource that it opens, using the name of the Service as key, for "down" to close.
+func (m *Manager) RegisterStateful(name string, up, down StateFunc) *Service {
+	return m.register(Service{name: name, upState: up, downState: down})
+}
+
+// TryRegister registers a single named Service like Register, but rejects nil "up" and "down" functions immediately
+// rather than during validation, so that the failure is attributed to the exact call site. TryRegister returns a
+// NilFuncError, and registers nothing, if either function is nil. Unlike Register, TryRegister never replaces a
+// registered Service, and returns a DuplicateServiceError instead.
+func (m *Manager) TryRegister(name string, up, down Func) (*Service, error) {
+	if up == nil || down == nil {
+		return nil, NilFuncError(name)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.frozen {
+		return nil, SealedManagerError(name)
+	}
+	if _, ok := m.services[name]; ok {
+		return nil, DuplicateServiceError(name)
+	}
+	return m.add(Service{name: name, up: up, down: down}), nil
+}
+
+// MustRegister registers a single named Service like TryRegister, but panics if TryRegister returns an error. It's
+// intended for programs where registration errors are programming errors, such as when registering Services from
+// main or init functions. Library code should use TryRegister instead.
+func (m *Manager) MustRegister(name string, up, down Func) *Service {
+	srvc, err := m.TryRegister(name, up, down)
+	if err != nil {
+		panic(err.Error())
+	}
+	return srvc
+}
+
+// RegisterStaged registers a single named Service with a two-stage startup: "up" warms up the Service, and "promote"
+// promotes it to serving. During the startup sequence, the up functions of all Services in a priority group are run
+// first, followed by the promote functions of the staged Services in the same group once every up function has
+// succeeded. The next priority group doesn't start until all promote functions have completed. This is useful for
+// servers that need to bind before they accept connections.
+// Staged Services are reported once, after their promote function has completed, unless their up function fails.
+// A nil promote function makes RegisterStaged behave like Register.
+func (m *Manager) RegisterStaged(name string, up, promote, down Func) *Service {
+	return m.register(Service{name: name, up: up, promote: promote, down: down})
+}
+
+// RegisterN registers n Services that share the same "up" and "down" functions, such as identical worker pools, under
+// the names baseName-1 through baseName-n, like Register. Each Service is a separate node in the boot sequence, and is
+// returned in order of its number, so that After can be called on each. RegisterN registers nothing if n is less
+// than one.
+func (m *Manager) RegisterN(baseName string, n int, up, down Func) []*Service {
+	services := make([]*Service, 0, max(n, 0))
+	for i := 1; i <= n; i++ {
+		services = append(services, m.Register(fmt.Sprintf("%s-%d", baseName, i), up, down))
+	}
+	return services
+}
+
+// finalizer is a named function that runs at the end of each shutdown sequence; see Manager.RegisterFinalizer.
+type finalizer struct {
+	name string
+	fn   Func
+}
+
+// RegisterFinalizer registers a named function that runs at the end of every shutdown sequence, after the "down"
+// functions of all Services, even if the shutdown sequence stopped early. This is useful for releasing resources
+// acquired before the boot sequence, such as a temporary directory. Finalizers never run during the startup
+// sequence, and as a shutdown sequence only starts once the startup sequence has stopped, they don't run at all if
+// the startup sequence never ran, in which case Agent.Down returns an InvalidStateError. Finalizers run one
+// at a time, in order of registration, and are reported like Services. A failed finalizer doesn't stop the ones after
+// it, but its error is joined with any other errors returned by the shutdown sequence.
+// RegisterFinalizer only affects Agents that are instantiated after the call. Like Register, it registers nothing if
+// the Manager is frozen; see Manager.Freeze.
+func (m *Manager) RegisterFinalizer(name string, fn Func) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject(name) {
+		return
+	}
+	m.opts.finalizers = append(append([]finalizer(nil), m.opts.finalizers...), finalizer{name, fn})
+}
+
+// register adds a Service with the name and functions of srvc. If a Service with the same name exists, it's replaced by
+// srvc, which keeps its position in the order of registration, unless it's a placeholder added by LoadManager: then
+// only its functions are filled in. If the Manager is frozen, register returns srvc without adding it, so that changes
+// to it are lost.
+func (m *Manager) register(srvc Service) *Service {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject(srvc.name) {
+		return &srvc
+	}
+
+	ref, ok := m.services[srvc.name]
+	if !ok {
+		return m.add(srvc)
+	}
+	if m.placeholders[srvc.name] {
+		delete(m.placeholders, srvc.name)
+		ref.up, ref.promote, ref.down = srvc.up, srvc.promote, srvc.down
+		ref.upState, ref.downState = srvc.upState, srvc.downState
+		ref.upCtx, ref.downCtx = srvc.upCtx, srvc.downCtx
+		return ref
+	}
+	srvc.order, srvc.mgr = ref.order, m
+	m.services[srvc.name] = &srvc
+	return &srvc
+}
+
+// add adds the given Service, which must not have been registered already, to the Manager. The caller must hold the
+// lock. add panics if the Manager has reached its limit of Services.
+func (m *Manager) add(srvc Service) *Service {
+	if len(m.services) >= m.limit {
+		panic(fmt.Sprintf(panicServiceLimit, m.limit))
+	}
+
+	srvc.order = len(m.services)
+	srvc.mgr = m
+	ref := &srvc
+	m.services[srvc.name] = ref
+	return ref
+}
+
+// Freeze returns a frozen copy of the Manager, which can be handed to other packages without the risk of them changing
+// the Services it contains. Any change to the copy, such as registering a Service, changing a setting with one of its
+// With methods, or changing a Service that it returned, is rejected and leaves the copy as it was. Validate and Agent
+// then return a SealedManagerError for the first rejected change, while TryRegister returns one right away. The copy
+// has the same Services and settings as the Manager at the time of the call, and is unaffected by later changes to
+// the Manager itself, or to the Services it returned, so Services can still be registered with the original.
+func (m *Manager) Freeze() *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	frozen := &Manager{
+		name:         m.name,
+		services:     make(unorderedServices, len(m.services)),
+		opts:         m.opts,
+		resolveAfter: m.resolveAfter,
+		limit:        m.limit,
+		frozen:       true,
+	}
+	for name, service := range m.services {
+		frozen.services[name] = service.clone()
+		frozen.services[name].mgr = frozen
+	}
+	return frozen
+}
+
+// reject returns true if the Manager is frozen, in which case the change with the given name, that of a Service or of
+// a Manager method, is rejected. The first rejected change is recorded as a SealedManagerError, which Validate and
+// Agent return from then on. The caller must hold the lock.
+func (m *Manager) reject(name string) bool {
+	if !m.frozen {
+		return false
+	}
+	if m.sealed == nil {
+		m.sealed = SealedManagerError(name)
+	}
+	return true
+}
+
+// SealOnAgent makes the Manager reject changes once it has instantiated an Agent, including changes to the Services it
+// returned earlier, in the same way as a Manager returned by Freeze. This catches initialisation code that registers
+// Services too late, as those would silently be left out of the boot sequence. The Manager is sealed by the first
+// successful call to Agent or AgentForTags, and stays sealed. SealOnAgent returns the Manager to allow for chaining.
+func (m *Manager) SealOnAgent() *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sealOnAgent = true
+	return m
+}
+
+// ServiceCount returns the number of services currently registered with the
+// Manager.
+func (m *Manager) ServiceCount() uint16 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return uint16(len(m.services))
+}
+
+// ServiceNames returns the name of each registered service, in no
+// particular order.
+func (m *Manager) ServiceNames() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ns := make([]string, 0, len(m.services))
+
+	for name := range m.services {
+		ns = append(ns, name)
+	}
+
+	return ns
+}
+
+// All returns an iterator over the registered Services and their names, sorted alphabetically by name. The Services
+// are copies taken under the lock when All is called, so the iteration doesn't hold the lock, and changes to the
+// copies don't affect the Manager. If the Manager validates, the priority of each Service is resolved; see
+// Service.Priority.
+func (m *Manager) All() iter.Seq2[string, *Service] {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.services))
+	services := make(map[string]*Service, len(m.services))
+	for name, service := range m.services {
+		names = append(names, name)
+		services[name] = service.clone()
+		services[name].priority = 0
+		services[name].mgr = nil
+	}
+	sort.Strings(names)
+
+	if resolved, err := m.resolve(); err == nil {
+		for priority, group := range m.opts.order(resolved) {
+			for _, service := range group {
+				services[service.name].priority = priority
+			}
+		}
+	}
+
+	return func(yield func(string, *Service) bool) {
+		for _, name := range names {
+			if !yield(name, services[name]) {
+				return
+			}
+		}
+	}
+}
+
+// ServiceNamesWithPrefix returns the name of each registered service that starts with the given prefix, such as "db."
+// for "db.primary" and "db.replica", sorted alphabetically.
+func (m *Manager) ServiceNamesWithPrefix(prefix string) []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var ns []string
+
+	for name := range m.services {
+		if strings.HasPrefix(name, prefix) {
+			ns = append(ns, name)
+		}
+	}
+	sort.Strings(ns)
+
+	return ns
+}
+
+// Depth returns the number of priority groups that the registered Services are ordered into, which is the length of
+// the longest chain of Services that come after one another, plus any groups added for exclusive Services. A high
+// depth relative to the number of Services indicates an over-serialised boot sequence. Depth returns 0 if the
+// Manager doesn't validate.
+func (m *Manager) Depth() uint16 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	services, err := m.resolve()
+	if err != nil {
+		return 0
+	}
+	return uint16(len(m.opts.order(services)))
+}
+
+// Agent orders the registered services by priority and returns an Agent for controlling the startup and shutdown
+// sequences. Agent returns an error if any of the registered Services refer to other Services that are not registered.
+// The Agent works on a copy of the registered Services, so registering more Services afterwards doesn't affect it.
+func (m *Manager) Agent() (agent *Agent, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	services, err := m.resolve()
+	if err != nil {
+		return
+	}
+	agent = newAgent(m.name, m.opts, m.opts.order(services))
+	m.frozen = m.frozen || m.sealOnAgent
+	return
+}
+
+// newAgent returns a new and idle Agent for the given Services, with a State of its own. The Services are copied.
+func newAgent(name string, opts options, ordered orderedServices) *Agent {
+	agent := &Agent{}
+	agent.name = name
+	agent.opts = opts
+	agent.orderedServices = make(orderedServices, len(ordered))
+	agent.st = newState()
+	for priority, services := range ordered {
+		bound := make([]Service, len(services))
+		for i, service := range services {
+			service.bind(agent.st)
+			bound[i] = service
+		}
+		agent.orderedServices[priority] = bound
+	}
+	return agent
+}
+
+// AgentForTags works like Agent, but the returned Agent only controls the Services tagged with any of the given tags,
+// along with the Services that they come after, directly or indirectly, so that the subset boots consistently.
+// AgentForTags returns an UnknownTagError if no Service has any of the tags.
+func (m *Manager) AgentForTags(tags ...string) (*Agent, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	services, err := m.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	subset := make(unorderedServices)
+	for name, srvc := range services {
+		for _, tag := range tags {
+			if srvc.hasTag(tag) {
+				subset[name] = srvc
+				break
+			}
+		}
+	}
+	if len(subset) == 0 {
+		return nil, UnknownTagError(strings.Join(tags, ", "))
+	}
+	for name := range subset {
+		for after := subset[name].after; after != ""; after = services[after].after {
+			subset[after] = services[after]
+		}
+	}
+	if err = subset.validate(); err != nil {
+		return nil, err
+	}
+
+	m.frozen = m.frozen || m.sealOnAgent
+	return newAgent(m.name, m.opts, m.opts.order(subset)), nil
+}
+
+// Graph returns the declared dependencies of the registered Services as adjacency lists: for each Service, the names of
+// the Services that it comes after, as set with Service.After. Services that don't come after any other have an empty
+// list. References are returned as declared, ie. without being resolved by the function set with
+// Manager.ResolveAfter, and without being checked for unregistered Services or cycles. The returned map is a copy.
+func (m *Manager) Graph() map[string][]string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	graph := make(map[string][]string, len(m.services))
+	for name, service := range m.services {
+		graph[name] = []string{}
+		if service.after != "" {
+			graph[name] = append(graph[name], service.after)
+		}
+	}
+
+	return graph
+}
+
+// SamePriorityPairs returns every pair of registered Services that are placed at the same priority, and might
+// therefore run concurrently. This is useful for reviewing whether any of them conflict. Each pair is sorted, and the
+// pairs are sorted too, for reasons of reproducibility. SamePriorityPairs returns nil if the Manager doesn't validate.
+func (m *Manager) SamePriorityPairs() [][2]string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	services, err := m.resolve()
+	if err != nil {
+		return nil
+	}
+
+	var pairs [][2]string
+	for _, group := range m.opts.order(services) {
+		names := make([]string, len(group))
+		for i, service := range group {
+			names[i] = service.name
+		}
+		sort.Strings(names)
+		for i := range names {
+			for j := i + 1; j < len(names); j++ {
+				pairs = append(pairs, [2]string{names[i], names[j]})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	return pairs
+}
+
+// Validate checks that the boot sequence has a name and at least one registered service.
+// Validate then cycles through each registered service and checks if they use a reserved name, refer to other service
+// names that don't exist, or if they refer to themselves, directly or through other Services, and whether two
+// exclusive Services are placed at the same priority. If a ResolveAfter function is set, the resolved references are
+// checked. Validate returns an error if this is the case, or nil otherwise.
+func (m *Manager) Validate() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, err := m.resolve()
+	return err
+}
+
+// resolve returns a copy of the registered Services, with references resolved by the ResolveAfter function, if any.
+// resolve returns an error if the copy doesn't validate. The caller must hold the lock.
+func (m *Manager) resolve() (unorderedServices, error) {
+	if m.sealed != nil {
+		return nil, m.sealed
+	}
+	if m.name == "" {
+		return nil, ErrEmptyName
+	}
+	if len(m.services) == 0 {
+		return nil, EmptySequenceError(m.name)
+	}
+
+	names := make([]string, 0, len(m.services))
+	for name := range m.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	services := make(unorderedServices, len(m.services))
+	for _, name := range names {
+		srvc := *m.services[name]
+		srvc.priority = 0
+		if m.resolveAfter != nil {
+			after := m.resolveAfter(name, append([]string(nil), names...))
+			switch len(after) {
+			case 0:
+				srvc.after = ""
+			case 1:
+				srvc.after = after[0]
+			default:
+				return nil, AmbiguousReferenceError(name)
+			}
+		}
+		services[name] = &srvc
+	}
+
+	if err := services.validate(); err != nil {
+		return nil, err
+	}
+	if err := services.validateExclusive(); err != nil {
+		return nil, err
+	}
+	return services, validateChoices(m.opts.choices, services)
+}
+
+// validate checks each Service in unorderedServices. See Manager.Validate.
+func (u unorderedServices) validate() error {
+	for name, srvc := range u {
+		if name == DoneService {
+			return ReservedNameError(name)
+		}
+		if (srvc.up == nil && srvc.upState == nil && srvc.upCtx == nil) ||
+			(srvc.down == nil && srvc.downState == nil && srvc.downCtx == nil) {
+			return NilFuncError(srvc.name)
+		}
+		if srvc.after == "" {
+			continue
+		}
+		if srvc.after == name {
+			return SelfReferenceError(srvc.after)
+		}
+		if _, ok := u[srvc.after]; !ok {
+			return UnregisteredServiceError(srvc.after)
+		}
+		// Follow the chain of references. Any chain longer than the number of Services contains a cycle.
+		next := srvc.after
+		for i := 0; next != "" && i < len(u); i++ {
+			prev, ok := u[next]
+			if !ok {
+				break // Reported when validating prev.
+			}
+			if prev.after == name {
+				return CyclicReferenceError(srvc.name)
+			}
+			next = prev.after
+		}
+	}
+
+	return nil
+}
+
+// ServiceCount returns the number of services currently registered with the Agent.
+func (a *Agent) ServiceCount() uint16 {
+	return uint16(a.orderedServices.length())
+}
+
+// String returns a string representation of the registered Services ordered by priority.
+// Service names are wrapped in parentheses, and separated by a colon when it might run concurrently with one or more
+// other services, and a right-arrow when it will run before another service.
+// Services that have the same priority are sorted alphabetically for reasons of reproducibility, or in order of
+// registration if configured with Manager.WithStableOrderByRegistration.
+func (a *Agent) String() string {
+	var sequence strings.Builder
+
+	for _, priority := range a.priorities(stateUp) {
+		sequence.WriteString("(" + strings.Join(a.names(priority), " : ") + ") > ")
+	}
+
+	ret := sequence.String()
+	return ret[:len(ret)-3]
+}
+
+// Tree returns a multi-line rendering of the boot sequence, which is easier to read than String for large sequences.
+// Each Service is listed on a line of its own, indented by two spaces per priority, so the Services of the first
+// priority group aren't indented, and the Services that come after them are indented beneath. The Services of each
+// group are sorted like String does. For example:
+//
+//	db
+//	queue
+//	  api
+//	  cache
+//	    web
+func (a *Agent) Tree() string {
+	var lines []string
+
+	for i, priority := range a.priorities(stateUp) {
+		indent := strings.Repeat("  ", i)
+		for _, name := range a.names(priority) {
+			lines = append(lines, indent+name)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ServiceNames returns the names of all Services in the order of their priority groups, with the Services of each group
+// sorted like String does. It's useful for logging the boot order without parsing the output of String.
+func (a *Agent) ServiceNames() []string {
+	var names []string
+
+	for _, priority := range a.priorities(stateUp) {
+		names = append(names, a.names(priority)...)
+	}
+
+	return names
+}
+
+// Linearize returns the names of all Services in a single order that satisfies every dependency: each Service comes
+// after the one it was registered to come after. Whenever more than one Service is ready to run, the one whose name
+// comes first alphabetically is picked, or the one registered first if configured with
+// Manager.WithStableOrderByRegistration. Unlike the priority groups of the sequence, which may run concurrently, the
+// result is one specific order that is useful for reproducing issues deterministically.
+func (a *Agent) Linearize() []string {
+	dependents := make(map[string][]Service)
+	var ready []Service
+	for _, services := range a.orderedServices {
+		for _, service := range services {
+			if service.after == "" {
+				ready = append(ready, service)
+			} else {
+				dependents[service.after] = append(dependents[service.after], service)
+			}
+		}
+	}
+
+	names := make([]string, 0, a.orderedServices.length())
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			return a.opts.less(ready[i], ready[j])
+		})
+		service := ready[0]
+		ready = append(ready[1:], dependents[service.name]...)
+		names = append(names, service.name)
+	}
+
+	return names
+}
+
+// VerifyReverse checks that the most recent shutdown sequence ran the priority groups of the startup sequence before
+// it in the exact reverse order, such as in a test that brings the Agent up and down again. It compares the Services
+// that each priority group ran, as recorded by the two sequences, and doesn't execute any Service Funcs itself.
+// Services that weren't picked from their group are left out; see Manager.OneOf. VerifyReverse returns a
+// ReverseOrderError describing the first position at which the two sequences aren't mirror images of each other, such
+// as after Agent.DownForward, or a shutdown sequence that stopped early. It returns an InvalidStateError if no
+// shutdown sequence has completed yet, and nil otherwise.
+func (a *Agent) VerifyReverse() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.state != stateDown || a.runEnd.IsZero() {
+		return InvalidStateError("cannot verify the order before a shutdown sequence has completed")
+	}
+	up, down := a.groups[stateUp], a.groups[stateDown]
+	if len(up) != len(down) {
+		return ReverseOrderError(fmt.Sprintf("startup has %d groups, shutdown has %d", len(up), len(down)))
+	}
+
+	for i := range up {
+		expected, actual := up[i], down[len(down)-1-i]
+		if expected != actual {
+			return ReverseOrderError(fmt.Sprintf("group %d: expected (%s), got (%s)", i+1, expected, actual))
+		}
+	}
+
+	return nil
+}
+
+// Up runs the startup sequence.
+// Up returns an error if the Agent's current state doesn't allow the sequence to start.
 func (a *Agent) Up(ctx context.Context, progressFn func(Progress)) error {
+	return a.up(ctx, withoutControl(progressFn))
+}
+
+// UpWithControl runs the startup sequence like Up, but allows fn to halt it. If fn returns a non-nil error, no further
+// priority groups are started, and UpWithControl returns that error once the current priority group has completed.
+// The error returned by fn for the final Progress report, which marks the end of the sequence, is ignored.
+func (a *Agent) UpWithControl(ctx context.Context, fn func(Progress) error) error {
+	return a.up(ctx, fn)
+}
+
+// UpWithLevelTimeout runs the startup sequence like Up, but allots each priority group the given time to complete,
+// rather than setting a single deadline for the entire sequence. If a priority group runs out of time, the sequence
+// stops with a LevelTimeoutError for that group, which unwraps to context.DeadlineExceeded. As with cancellation,
+// Services that are in progress still run to completion.
+func (a *Agent) UpWithLevelTimeout(ctx context.Context, per time.Duration, progressFn func(Progress)) error {
+	if err := a.begin(withoutControl(progressFn)); err != nil {
+		return err
+	}
+	a.levelTimeout = per
+	return a.run(ctx)
+}
+
+// UpReadyOn runs the startup sequence in a separate goroutine, and returns as soon as the priority group of the
+// Service with the given name has come up, while the rest of the sequence continues in the background. The returned
+// channel receives the result of the full startup sequence once it has completed.
+// UpReadyOn returns an error if the Service isn't part of the sequence, if the Agent's current state doesn't allow the
+// sequence to start, or if the sequence stopped before the Service came up. In the latter case, the same error is
+// also delivered on the returned channel.
+func (a *Agent) UpReadyOn(ctx context.Context, ready string, progressFn func(Progress)) (<-chan error, error) {
+	priority, ok := a.priorityOf(ready)
+	if !ok {
+		return nil, UnregisteredServiceError(ready)
+	}
+	if err := a.begin(withoutControl(progressFn)); err != nil {
+		return nil, err
+	}
+
+	a.readyAt = priority
+	a.ready = make(chan struct{})
+	readyCh := a.ready
+	result := make(chan error, 1)
+	go func() {
+		result <- a.run(ctx)
+	}()
+
+	select {
+	case <-readyCh:
+		return result, nil
+	case err := <-result:
+		result <- err
+		return result, err
+	}
+}
+
+// UpPrefix runs the startup sequence like Up, but only for the Services whose names start with the given prefix, along
+// with the Services that they come after, directly or indirectly. The Agent keeps to this selection of Services from
+// then on, so a subsequent call to Down only shuts down the Services that UpPrefix started.
+// UpPrefix returns an UnregisteredServiceError if no Service name starts with the prefix.
+func (a *Agent) UpPrefix(ctx context.Context, prefix string, progressFn func(Progress)) error {
+	selectFn := func() (orderedServices, error) {
+		selected := a.withPredecessors(func(name string) bool {
+			return strings.HasPrefix(name, prefix)
+		})
+		if selected.length() == 0 {
+			return nil, UnregisteredServiceError(prefix)
+		}
+		return selected, nil
+	}
+	if err := a.beginSelected(withoutControl(progressFn), selectFn); err != nil {
+		return err
+	}
+	return a.run(ctx)
+}
+
+// withPredecessors returns the Services for which match returns true, along with the Services that they come after,
+// directly or indirectly, ordered by priority. Priority groups left without any Services are removed, so that the
+// priorities of the returned Services remain contiguous.
+func (a *Agent) withPredecessors(match func(name string) bool) orderedServices {
+	selected := make(map[string]bool)
+	for _, priority := range a.priorities(stateDown) {
+		for _, service := range a.orderedServices[priority] {
+			if match(service.name) || selected[service.name] {
+				selected[service.name] = true
+				if service.after != "" {
+					selected[service.after] = true
+				}
+			}
+		}
+	}
+
+	ordered := make(orderedServices)
+	next := uint16(0)
+	for _, priority := range a.priorities(stateUp) {
+		var group []Service
+		for _, service := range a.orderedServices[priority] {
+			if selected[service.name] {
+				group = append(group, service)
+			}
+		}
+		if len(group) > 0 {
+			next++
+			ordered[next] = group
+		}
+	}
+	return ordered
+}
+
+// UpStress runs the startup sequence followed by the shutdown sequence the given number of times, back-to-back, in
+// order to help flush out data races in concurrent Service Funcs when testing with the -race flag. Each iteration runs
+// on a fresh copy of the Agent, with a State of its own, so the receiver itself is never started. Progress isn't
+// reported. UpStress stops early if ctx is done.
+// UpStress returns the errors of all iterations joined together, each one prefixed by its iteration number, or an
+// error if the Agent isn't idle.
+func (a *Agent) UpStress(ctx context.Context, iterations int) error {
+	a.lock.Lock()
+	st := a.state
+	a.lock.Unlock()
+	if st != stateIdle {
+		return InvalidStateError(inProgressErrorMessage)
+	}
+
+	var errs []error
+	for i := 1; i <= iterations; i++ {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("iteration %d: %w", i, context.Cause(ctx)))
+			break
+		}
+		clone := newAgent(a.name, a.opts, a.orderedServices)
+		err := clone.Up(ctx, nil)
+		if err == nil {
+			err = clone.Down(ctx, nil)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("iteration %d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ConcurrentWith returns the names of the other Services in the priority group of the Service with the given name,
+// sorted like Agent.String does. These are the Services that may run concurrently with it. ConcurrentWith returns nil
+// if the Service isn't part of the sequence, or if the Manager was configured to run Services one at a time with
+// Manager.WithMaxConcurrency.
+func (a *Agent) ConcurrentWith(name string) []string {
+	priority, ok := a.priorityOf(name)
+	if !ok || a.opts.isSerial() {
+		return nil
+	}
+
+	others := make([]string, 0, len(a.orderedServices[priority])-1)
+	for _, other := range a.names(priority) {
+		if other != name {
+			others = append(others, other)
+		}
+	}
+	return others
+}
+
+// IsParallel returns true if any priority group contains more than one Service, ie. if the plan allows for any
+// Services to run concurrently. A plan in which every Service comes after another returns false, which may indicate
+// an over-serialised boot sequence. Like ConcurrentWith, IsParallel returns false if the Manager was configured to run
+// Services one at a time with Manager.WithMaxConcurrency.
+func (a *Agent) IsParallel() bool {
+	if a.opts.isSerial() {
+		return false
+	}
+	for _, services := range a.orderedServices {
+		if len(services) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// priorityOf returns the priority of the Service with the given name, and whether the Service was found.
+func (a *Agent) priorityOf(name string) (uint16, bool) {
+	for priority, services := range a.orderedServices {
+		for _, service := range services {
+			if service.name == name {
+				return priority, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// up runs the startup sequence, reporting to progressFn.
+func (a *Agent) up(ctx context.Context, progressFn func(Progress) error) error {
+	if err := a.begin(progressFn); err != nil {
+		return err
+	}
+	return a.run(ctx)
+}
+
+// begin moves an idle Agent into the startup state, reporting to progressFn. begin returns an error if the Agent's
+// current state doesn't allow the sequence to start.
+func (a *Agent) begin(progressFn func(Progress) error) error {
+	return a.beginSelected(progressFn, nil)
+}
+
+// beginSelected is like begin, but if selectFn isn't nil, the Agent keeps to the Services it returns from then on.
+// selectFn is called while the Agent is locked, so that the selection is made and applied in one step. If selectFn
+// returns an error, the Agent stays idle and beginSelected returns the error.
+func (a *Agent) beginSelected(progressFn func(Progress) error, selectFn func() (orderedServices, error)) error {
 	a.lock.Lock()
 	if a.state != stateIdle {
 		msg := inProgressErrorMessage
@@ -287,116 +1860,1015 @@ func (a *Agent) Up(ctx context.Context, progressFn func(Progress)) error {
 		a.lock.Unlock()
 		return InvalidStateError(msg)
 	}
+	if selectFn != nil {
+		selected, err := selectFn()
+		if err != nil {
+			a.lock.Unlock()
+			return err
+		}
+		a.orderedServices = selected
+	}
+
+	a.state = stateUp
+	a.isDone = false
+	a.progress = &reporter{name: a.name, state: stateUp, fn: progressFn}
+	a.levelTimeout = 0
+	a.failures = make(map[string]error)
+	a.started = make(map[string]bool)
+	a.upOK = make(map[string]bool)
+	a.timings = make(map[string]time.Duration)
+	a.spans = make(map[string][2]time.Time)
+	a.groups = make(map[state][]string)
+	a.runStart, a.runEnd = time.Now(), time.Time{}
+	a.stopped = NotStopped
+	a.lock.Unlock()
+	return nil
+}
+
+// run runs the warmup functions followed by the startup sequence.
+func (a *Agent) run(ctx context.Context) error {
+	ctx, cancel := timeoutContext(ctx, a.opts.upTimeout)
+	defer cancel()
+
+	a.lock.Lock()
+	r := a.progress
+	a.choose()
+	a.lock.Unlock()
+	r.remaining.Store(int32(a.orderedServices.length()))
+	if err := a.warmup(ctx, r); err != nil {
+		reason := StoppedServiceError
+		if ctx.Err() != nil {
+			reason = stopReasonOf(ctx)
+		}
+		a.finish(r, reason)
+		a.complete(err)
+		return err
+	}
+	return a.exec(ctx, r)
+}
+
+// finish records the end of run r, along with the reason it stopped. Services abandoned by r no longer affect the
+// state of the Agent from then on.
+func (a *Agent) finish(r *reporter, reason StopReason) {
+	a.lock.Lock()
+	r.ended = true
+	a.runEnd = time.Now()
+	a.stopped = reason
+	a.lock.Unlock()
+}
+
+// OnUpComplete sets a function to call once each time the startup sequence has completed, successfully or not. It
+// receives the error returned by the sequence, which is nil on success. Unlike progress reports, fn is called only
+// once per sequence.
+func (a *Agent) OnUpComplete(fn func(error)) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.onUpComplete = fn
+}
+
+// OnDownComplete sets a function to call once each time the shutdown sequence has completed, successfully or not. It
+// receives the error returned by the sequence, which is nil on success, including the joined errors of a best-effort
+// shutdown.
+func (a *Agent) OnDownComplete(fn func(error)) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.onDownComplete = fn
+}
+
+// complete calls the completion function for the current state, if any, with the error returned by the sequence.
+func (a *Agent) complete(err error) {
+	a.lock.Lock()
+	fn := a.onUpComplete
+	if a.state == stateDown {
+		fn = a.onDownComplete
+	}
+	a.lock.Unlock()
+
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// StopReason returns the outcome of the most recent startup or shutdown sequence, or NotStopped if no sequence has
+// run yet, or the current one is still in progress.
+func (a *Agent) StopReason() StopReason {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.stopped
+}
+
+// CanUp returns true if the Agent is idle, in which case a call to Up, or any of its variants, would start the startup
+// sequence rather than return an InvalidStateError. As the Agent may be used concurrently, the answer may be stale by
+// the time it's acted upon.
+func (a *Agent) CanUp() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.state == stateIdle
+}
+
+// CanDown returns true if the startup sequence has stopped and the shutdown sequence hasn't started, in which case a
+// call to Down or DownForward would start the shutdown sequence rather than return an InvalidStateError. As the Agent
+// may be used concurrently, the answer may be stale by the time it's acted upon.
+func (a *Agent) CanDown() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.state == stateUp && !a.runEnd.IsZero()
+}
+
+// Down runs the shutdown sequence. Only the Services that the startup sequence brought up are shut down, so Down can
+// also follow a startup sequence that failed or was cancelled, in which case it shuts down the Services that came up
+// before it stopped. Down returns an error if the Agent's current state doesn't allow the sequence to start, such as
+// while the startup sequence is still running.
+func (a *Agent) Down(ctx context.Context, progressFn func(Progress)) error {
+	return a.down(ctx, progressFn, false)
+}
+
+// DownOnce runs the shutdown sequence like Down, but only the first time it's called. Subsequent and concurrent calls
+// wait for the first one to complete, and return the same result, which is nil if the shutdown sequence succeeded.
+// This makes it safe to trigger the shutdown sequence from several places, such as a signal handler and a deferred
+// call. progressFn is only used by the first call. A call that can't start the shutdown sequence, such as before the
+// startup sequence has stopped, returns an InvalidStateError like Down, and leaves it to a later call.
+func (a *Agent) DownOnce(ctx context.Context, progressFn func(Progress)) error {
+	a.downLock.Lock()
+	defer a.downLock.Unlock()
+
+	if a.downRan {
+		return a.downErr
+	}
+	r, err := a.beginDown(progressFn, false)
+	if err != nil {
+		return err
+	}
+	a.downRan = true
+	a.downErr = a.runDown(ctx, r)
+	return a.downErr
+}
+
+// DownForward runs the shutdown sequence like Down, but traverses the priority groups in the same order as the
+// startup sequence rather than in reverse. This suits resources that must be released in the order they were
+// acquired, such as leases.
+func (a *Agent) DownForward(ctx context.Context, progressFn func(Progress)) error {
+	return a.down(ctx, progressFn, true)
+}
+
+// down runs the shutdown sequence, traversing the priority groups in the order of the startup sequence if forward is
+// true.
+func (a *Agent) down(ctx context.Context, progressFn func(Progress), forward bool) error {
+	r, err := a.beginDown(progressFn, forward)
+	if err != nil {
+		return err
+	}
+	return a.runDown(ctx, r)
+}
+
+// beginDown moves an Agent that has come up into the shutdown state, and returns the reporter of the shutdown sequence,
+// which reports to progressFn. beginDown returns an error if the Agent's current state doesn't allow the sequence to
+// start.
+func (a *Agent) beginDown(progressFn func(Progress), forward bool) (*reporter, error) {
+	a.lock.Lock()
+	if a.state != stateUp || a.runEnd.IsZero() {
+		msg := ""
+		switch a.state {
+		case stateIdle:
+			msg = idleErrorMessage
+		case stateUp:
+			msg = upErrorMessage
+		case stateDown:
+			msg = inProgressErrorMessage
+		}
+		a.lock.Unlock()
+		return nil, InvalidStateError(msg)
+	}
+
+	a.state = stateDown
+	r := &reporter{name: a.name, state: stateDown, fn: withoutControl(progressFn)}
+	r.remaining.Store(int32(len(a.started) + len(a.opts.finalizers)))
+	a.progress = r
+	a.forward = forward
+	a.levelTimeout = 0
+	a.isDone = false
+	a.failures = make(map[string]error)
+	a.timings = make(map[string]time.Duration)
+	a.spans = make(map[string][2]time.Time)
+	a.groups[stateDown] = nil
+	a.runStart, a.runEnd = time.Now(), time.Time{}
+	a.stopped = NotStopped
+	a.lock.Unlock()
+	return r, nil
+}
+
+// runDown runs the shutdown sequence that beginDown has started, reporting to r.
+func (a *Agent) runDown(ctx context.Context, r *reporter) error {
+	ctx, cancel := timeoutContext(ctx, a.opts.downTimeout)
+	defer cancel()
+	return a.exec(ctx, r)
+}
+
+// Tee returns a progress function that calls each of the given progress functions in order, for every Progress it
+// receives. Nil functions are skipped. This allows for combining, for example, logging and metrics for the same run.
+// The functions are called on the goroutine that reports the Progress. As the Services of a priority group report
+// their progress concurrently, each function must be safe for concurrent use on its own.
+func Tee(fns ...func(Progress)) func(Progress) {
+	return func(p Progress) {
+		for _, fn := range fns {
+			if fn != nil {
+				fn(p)
+			}
+		}
+	}
+}
+
+// withoutControl adapts a progress function that cannot halt the sequence. It returns nil if fn is nil.
+func withoutControl(fn func(Progress)) func(Progress) error {
+	if fn == nil {
+		return nil
+	}
+	return func(progress Progress) error {
+		fn(progress)
+		return nil
+	}
+}
+
+// reporter reports the Progress of a single run of an Agent. Each run gets a reporter of its own, which is handed to
+// the functions executing the run rather than looked up on the Agent, so that Services abandoned by an interrupted run
+// keep reporting to that run, and never to the progress function of the next one; see Manager.WithCancelGrace.
+// Apart from remaining and ended, its fields are set before the Services of the run execute, and never change
+// afterwards.
+type reporter struct {
+	name      string                  // Name of boot sequence.
+	state     state                   // State of the run: up/down.
+	fn        func(Progress) error    // Progress reporting; a non-nil error halts the sequence.
+	halt      context.CancelCauseFunc // Cancels the running sequence with a cause; set by Agent.exec.
+	remaining atomic.Int32            // Number of Services that haven't completed during the run; see Progress.Remaining.
+	ended     bool                    // Has the run ended? Guarded by Agent.lock; see Agent.finish.
+}
+
+// report calls the provided progress function with the given Progress struct. It sets the name of the boot sequence,
+// and the Kind of reports with an error, unless already set, based on the state of the run. If the progress function
+// returns an error, the running sequence is halted with the error as its cause. Without a progress function, report
+// returns right away: the Progress is passed by value, so it's never allocated, and the count of remaining Services,
+// which only serves Progress.Remaining, is left alone.
+func (r *reporter) report(progress Progress) {
+	if r.fn == nil {
+		return
+	}
+	progress.Sequence = r.name
+	if progress.Service != DoneService && progress.Kind != WarmupError && progress.Kind != RollbackError {
+		progress.Remaining = int(r.remaining.Add(-1)) // Reports for Services mark their completion.
+	} else {
+		progress.Remaining = int(r.remaining.Load())
+	}
+	if progress.Err != nil && progress.Kind == NoError {
+		progress.Kind = UpError
+		if r.state == stateDown {
+			progress.Kind = DownError
+		}
+	}
+	if err := r.fn(progress); err != nil && r.halt != nil {
+		r.halt(err)
+	}
+}
 
-	a.state = stateUp
-	a.isDone = false
-	a.progressFn = progressFn
-	a.lock.Unlock()
+// before calls the BeforeEach function, if any, for the Service with the given name and phase.
+func (a *Agent) before(name, phase string) {
+	if a.opts.beforeEach == nil {
+		return
+	}
+	a.opts.beforeEach(name, phase)
+}
+
+// warmup runs the warmup Funcs of all Services, one priority group at a time in the order of the startup sequence.
+// Services that weren't picked from their group are left out; see Manager.OneOf. Only failures are reported, to r.
+// warmup returns the first error encountered, or the cause of the cancellation if ctx is cancelled between two
+// priority groups. Like exec, it ends a failed warmup with the report of the failing Service, and an interrupted one
+// with a DoneService report of kind WarmupError.
+func (a *Agent) warmup(ctx context.Context, r *reporter) error {
+	for _, priority := range a.priorities(stateUp) {
+		if ctx.Err() != nil {
+			err := context.Cause(ctx)
+			r.report(Progress{Service: DoneService, Err: err, Kind: WarmupError})
+			return err
+		}
+
+		var warm []Service
+		for _, service := range a.group(priority) {
+			if service.warmup != nil && !a.isSkipped(service.name) {
+				warm = append(warm, service)
+			}
+		}
+		if len(warm) == 0 {
+			continue // Avoid running groups without warmup functions.
+		}
+
+		var failed atomic.Bool // Is a failure reported? If not, the group was interrupted.
+		tasks := make([]func() error, len(warm))
+		for i, service := range warm {
+			service := service
+			tasks[i] = func() error {
+				a.before(service.name, "warmup")
+				err := service.warmup()
+				a.fail(r, service.name, err)
+				if err != nil {
+					failed.Store(true)
+					r.report(Progress{Service: service.name, Err: err, Kind: WarmupError, Meta: service.meta})
+				}
+				return err
+			}
+		}
+		if err := a.opts.group().Run(ctx, tasks); err != nil {
+			if !failed.Load() {
+				r.report(Progress{Service: DoneService, Err: err, Kind: WarmupError})
+			}
+			return err
+		}
+	}
 
-	return a.exec(ctx)
+	return nil
 }
 
-// Down runs the shutdown sequence.
-// Down returns an error if the Agent's current state doesn't allow the sequence to start.
-func (a *Agent) Down(ctx context.Context, progressFn func(Progress)) error {
-	a.lock.Lock()
-	if a.state != stateUp || !a.isDone {
-		msg := ""
-		switch a.state {
-		case stateIdle:
-			msg = idleErrorMessage
-		case stateUp:
-			msg = upErrorMessage
-		case stateDown:
-			msg = inProgressErrorMessage
+// priorities returns the priorities of the Agent's priority groups in order of execution for the given state. Groups
+// are executed in the direction from priority 1..n for startup sequences, and from priority n..1 for shutdown
+// sequences.
+func (a *Agent) priorities(st state) []uint16 {
+	n := uint16(len(a.orderedServices))
+	ps := make([]uint16, n)
+	for i := uint16(0); i < n; i++ {
+		if st == stateDown {
+			ps[i] = n - i
+		} else {
+			ps[i] = i + 1
 		}
-		a.lock.Unlock()
-		return InvalidStateError(msg)
 	}
+	return ps
+}
 
-	a.state = stateDown
-	a.isDone = false
-	a.progressFn = progressFn
-	a.lock.Unlock()
+// group returns the Services in the priority group with the given priority. When Services run one at a time, or if
+// configured with Manager.WithDeterministicWithinLevel, they are sorted; see options.less.
+func (a *Agent) group(priority uint16) []Service {
+	if !a.opts.isSerial() && !a.opts.sortGroups {
+		return a.orderedServices[priority]
+	}
+	return a.sorted(priority)
+}
 
-	return a.exec(ctx)
+// sorted returns a sorted copy of the Services in the priority group with the given priority; see options.less.
+func (a *Agent) sorted(priority uint16) []Service {
+	services := a.orderedServices[priority]
+	sorted := make([]Service, len(services))
+	copy(sorted, services)
+	sort.Slice(sorted, func(i, j int) bool {
+		return a.opts.less(sorted[i], sorted[j])
+	})
+	return sorted
 }
 
-// report calls the provided progressFn with the given Progress struct.
-func (a *Agent) report(progress Progress) {
-	if a.progressFn == nil {
-		return
+// names returns the names of the Services in the priority group with the given priority, sorted alphabetically, or in
+// order of registration if configured with Manager.WithStableOrderByRegistration.
+func (a *Agent) names(priority uint16) []string {
+	services := a.sorted(priority)
+	names := make([]string, len(services))
+	for i, service := range services {
+		names[i] = service.name
 	}
-	a.progressFn(progress)
+	return names
 }
 
 // exec runs through the sequence step by step and runs the relevant Service Func.
 // The standard behaviour is to traverse the sequence in chronological order and run the "up" Func. If Agent.state ==
 // downState, the traversal is instead done in reverse order, and the "down" Func will run instead. After each Service
-// has completed, progressFn is called (if provided) with a Progress struct.
-func (a *Agent) exec(ctx context.Context) error {
-	var err error
+// has completed, it's reported to r with a Progress struct.
+// exec derives a cancellable context from ctx. When the sequence is interrupted, the cause of the cancellation (see
+// context.Cause) is returned rather than the plain context error.
+// Once a shutdown sequence has stopped, for whatever reason, exec runs the finalizers; see Manager.RegisterFinalizer.
+func (a *Agent) exec(ctx context.Context, r *reporter) (err error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	r.halt = cancel
+
+	reason := StoppedServiceError
+	final := false // Is the end of the sequence reported? Not when a priority group fails.
 	defer func() {
+		if a.state == stateDown {
+			if ferr := a.finalize(r); ferr != nil {
+				err = errors.Join(err, ferr)
+			}
+		}
+		if final {
+			r.report(Progress{Service: DoneService, Err: err})
+		}
 		if err == nil {
+			reason = StoppedComplete
 			a.lock.Lock()
 			a.isDone = true
 			a.lock.Unlock()
 		}
+		a.finish(r, reason)
+		a.complete(err)
 	}()
 
-	var (
-		current = 0
-		step    = 1
-		done    = make(chan error)
-	)
-	if a.state == stateDown {
-		current = len(a.orderedServices) + 1
-		step = -1
-	}
+	done := make(chan error, 1) // Buffered, so that abandoned priority groups can still finish.
+	var failed error // The last error of a priority group during a best-effort shutdown, if any.
 
-	// Iterate over priority groups. Move in the direction from priority 1..n for startup sequences, and from
-	// priority n..1 for shutdown sequences. There is no guarantee regarding order of execution within each
-	// priority group. It's possible to interrupt the sequence between each priority group.
-	for i := 0; i < len(a.orderedServices); i++ {
-		current += step
+	// Iterate over priority groups. There is no guarantee regarding order of execution within each priority group.
+	// It's possible to interrupt the sequence between each priority group.
+	direction := a.state
+	if a.forward {
+		direction = stateUp
+	}
+	for _, priority := range a.priorities(direction) {
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+			reason = stopReasonOf(ctx)
+			final = true
+			return err
+		}
 
-		go a.execPriority(ctx, uint16(current), done)
+		levelCtx, cancelLevel := a.levelContext(ctx, priority)
+		go a.execPriority(levelCtx, r, priority, done)
 
 		select {
-		case <-ctx.Done():
-			err = ctx.Err()
-			<-done // Wait for execPriority to finish before stopping execution.
-			a.report(Progress{Service: "", Err: err})
+		case <-levelCtx.Done():
+			err = context.Cause(levelCtx)
+			reason = stopReasonOf(levelCtx)
+			if abandoned := a.await(priority, done); len(abandoned) > 0 {
+				err = errors.Join(err, AbandonedServicesError(strings.Join(abandoned, ", ")))
+			}
+			cancelLevel()
+			final = true
 			return err
 		case err = <-done:
+			cancelLevel()
+			if err != nil && a.state == stateDown && a.opts.bestEffort {
+				failed = err
+				continue
+			}
 			if err != nil {
 				return err
 			}
+			if a.ready != nil && priority == a.readyAt {
+				close(a.ready)
+				a.ready = nil
+			}
 			continue
 		}
 	}
 
-	a.report(Progress{Service: "", Err: err})
-	return err
+	final = true
+	if failed != nil {
+		// A GroupRunner may fail without any of the Services having failed, in which case its own error is returned.
+		if merr := a.multiError(direction); len(merr) > 0 {
+			return merr
+		}
+		return failed
+	}
+	return nil
+}
+
+// finalize runs the finalizers set with Manager.RegisterFinalizer in order of registration, reporting each one to r. It
+// returns the errors of failed finalizers joined together, or nil if all of them succeeded.
+func (a *Agent) finalize(r *reporter) error {
+	var errs []error
+	for _, f := range a.opts.finalizers {
+		err := f.fn()
+		a.fail(r, f.name, err)
+		r.report(Progress{Service: f.name, Err: err})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// await waits for execPriority to finish running the priority group with the given priority, which it signals on done.
+// If the Agent has a grace period, await stops waiting once it expires, and returns the names of the Services of the
+// group that haven't finished by then; see Manager.WithCancelGrace.
+func (a *Agent) await(priority uint16, done <-chan error) []string {
+	if a.opts.grace <= 0 {
+		<-done
+		return nil
+	}
+
+	timer := time.NewTimer(a.opts.grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var abandoned []string
+	for _, service := range a.sorted(priority) {
+		if a.state == stateDown && !a.started[service.name] {
+			continue // Never torn down.
+		}
+		if _, ok := a.spans[service.name]; !ok && !a.skipped[service.name] {
+			abandoned = append(abandoned, service.name)
+		}
+	}
+	return abandoned
+}
+
+// levelContext derives the context for running the priority group with the given priority from ctx. If the Agent has
+// a level timeout, the derived context is cancelled with a LevelTimeoutError once it expires.
+func (a *Agent) levelContext(ctx context.Context, priority uint16) (context.Context, context.CancelFunc) {
+	if a.levelTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, a.levelTimeout, LevelTimeoutError(priority))
 }
 
 // execPriority executes all Services with the same priority/order.
-// execPriority creates an errgroup for a single priority level in the Agent's orderedServices slice and runs them.
-// execPriority returns an error if any one of the Services in the errgroup failed.
+// execPriority runs the Services of a single priority level in the Agent's orderedServices slice using the GroupRunner.
+// During startup, the promote functions of any staged Services are run once all Services in the group are up. During
+// shutdown, Services that weren't brought up are skipped.
+// execPriority returns an error if any one of the Services in the group failed.
 // execPriority is uninterruptible at this level.
-func (a *Agent) execPriority(ctx context.Context, priority uint16, done chan<- error) {
-	grp, _ := errgroup.WithContext(ctx)
+// The time spent by each Service is measured whether or not the sequence is observed, as Agent.Snapshot,
+// Agent.CriticalPath, Agent.RunJSON and Manager.WithCancelGrace rely on it after the fact.
+// The context passed to ContextFuncs carries the budget of the sequence; see BudgetFromContext.
+// Progress is reported to r, and the Services run for its state, even if they outlive the run; see reporter.
+func (a *Agent) execPriority(ctx context.Context, r *reporter, priority uint16, done chan<- error) {
+	ctx = a.withBudget(ctx, priority)
+	tasks := make([]func() error, 0, len(a.orderedServices[priority]))
+	weights := make([]int, 0, len(a.orderedServices[priority]))
+	names := make([]string, 0, len(a.orderedServices[priority]))
+	staged := false
+
+	for _, service := range a.group(priority) {
+		if r.state == stateDown && !a.isStarted(service.name) {
+			continue // Never tear down a Service that wasn't brought up.
+		}
+		if r.state == stateUp && a.isSkipped(service.name) {
+			r.report(Progress{Service: service.name, Meta: service.meta, Skipped: true})
+			continue
+		}
+		service := service
+		isStaged := r.state == stateUp && service.promote != nil
+		staged = staged || isStaged
+		weights = append(weights, max(service.weight, 1))
+		names = append(names, service.name)
+		tasks = append(tasks, func() error {
+			a.before(service.name, r.state.String())
+			start := time.Now()
+			reported := a.call(ctx, r.state, service) // Execute the Service Func.
+			a.measure(r, service.name, start)
+			err := a.filter(service.name, reported)
+			if err != nil {
+				reported = err
+			}
+			a.fail(r, service.name, err)
+			if err == nil && r.state == stateUp {
+				a.markStarted(r, service.name)
+			}
+			if !isStaged || err != nil {
+				r.report(Progress{Service: service.name, Err: reported, Meta: service.meta})
+			}
+			return err
+		})
+	}
+
+	a.record(r, names)
+	err := a.opts.weighted(weights).Run(ctx, tasks)
+	if err == nil && staged {
+		err = a.execPromote(ctx, r, priority)
+	}
+	if err != nil && r.state == stateUp {
+		a.rollback(context.WithoutCancel(ctx), r, priority)
+	}
+	done <- err
+}
+
+// record records the names of the Services that a priority group of run r executes, unless none of them do; see
+// Agent.VerifyReverse.
+func (a *Agent) record(r *reporter, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	a.lock.Lock()
+	if !r.ended {
+		a.groups[r.state] = append(a.groups[r.state], strings.Join(names, " : "))
+	}
+	a.lock.Unlock()
+}
 
+// rollback runs the "down" functions of the Services that came up during the current startup sequence, and that
+// belong to the transaction group of a Service with the given priority that failed; see Service.TransactionGroup.
+// Services are rolled back one at a time, in reverse order of priority. Only failed rollbacks are reported, with
+// their Kind set to RollbackError, to r. ctx is passed on to any ContextFuncs. Nothing is rolled back once r has
+// ended, as the Agent then keeps the state of the next run.
+func (a *Agent) rollback(ctx context.Context, r *reporter, priority uint16) {
+	failed := make(map[string]bool)
+	a.lock.Lock()
+	if r.ended {
+		a.lock.Unlock()
+		return
+	}
 	for _, service := range a.orderedServices[priority] {
+		if _, ok := a.failures[service.name]; ok && service.tx != "" {
+			failed[service.tx] = true
+		}
+	}
+	a.lock.Unlock()
+	if len(failed) == 0 {
+		return
+	}
+
+	for _, p := range a.priorities(stateDown) {
+		for _, service := range a.sorted(p) {
+			if !failed[service.tx] || !a.isStarted(service.name) {
+				continue
+			}
+			err := service.funcWithContext(ctx, stateDown)()
+			a.fail(r, service.name, err)
+			a.lock.Lock()
+			if !r.ended {
+				delete(a.started, service.name)
+			}
+			a.lock.Unlock()
+			if err != nil {
+				r.report(Progress{Service: service.name, Err: err, Kind: RollbackError, Meta: service.meta})
+			}
+		}
+	}
+}
+
+// injection is a failure injected into an Agent for a single Service.
+type injection struct {
+	err        error
+	persistent bool // Does the injection remain after it has been used?
+}
+
+// InjectFailure makes the next call to a Service Func of the Service with the given name return err instead, during
+// either the startup or the shutdown sequence, which is useful for testing rollback and error handling
+// deterministically. The failure is reported through progress reports as usual. The injection is consumed once it
+// has been used, so a retry calls the real Service Func; see Agent.InjectPersistentFailure for injections that remain.
+func (a *Agent) InjectFailure(name string, err error) {
+	a.inject(name, injection{err: err})
+}
+
+// InjectPersistentFailure works like InjectFailure, but the injection remains until removed with
+// Agent.ClearInjections, so that every Service Func of the Service fails.
+func (a *Agent) InjectPersistentFailure(name string, err error) {
+	a.inject(name, injection{err: err, persistent: true})
+}
+
+// ClearInjections removes all failures injected with Agent.InjectFailure or Agent.InjectPersistentFailure.
+func (a *Agent) ClearInjections() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.injections = nil
+}
+
+// inject records the injection for the Service with the given name, replacing any earlier one.
+func (a *Agent) inject(name string, inj injection) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.injections == nil {
+		a.injections = make(map[string]injection)
+	}
+	a.injections[name] = inj
+}
+
+// consumeInjection returns the injection for the Service with the given name, if any, removing it unless it's
+// persistent.
+func (a *Agent) consumeInjection(name string) (injection, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	inj, ok := a.injections[name]
+	if ok && !inj.persistent {
+		delete(a.injections, name)
+	}
+	return inj, ok
+}
+
+// call executes the Service Func of the given Service for the given state. During the startup sequence, a failed
+// Service Func is retried as configured with Service.WithRetry, until it succeeds or ctx is done. Each attempt returns
+// the injected failure for the Service, if any, rather than calling the Service Func.
+func (a *Agent) call(ctx context.Context, ph state, service Service) error {
+	fn := func(ctx context.Context) error {
+		if inj, ok := a.consumeInjection(service.name); ok {
+			return inj.err
+		}
+		return service.funcWithContext(ctx, ph)()
+	}
+	call := func() error { return fn(ctx) }
+	if service.timeout > 0 {
+		call = withTimeout(ctx, service, fn)
+	}
+
+	err := call()
+	if ph != stateUp {
+		return err
+	}
+
+	for attempt := 1; err != nil && attempt <= service.retries; attempt++ {
+		if a.opts.onRetry != nil {
+			a.opts.onRetry(service.name, attempt, err)
+		}
+		timer := time.NewTimer(service.backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = call()
+	}
+	return err
+}
+
+// withTimeout returns a function that calls fn in a new goroutine, and waits for it to return until the timeout of
+// the given Service has passed, or ctx is done. It then returns the cause of the cancellation: a ServiceTimeoutError
+// if the timeout of the Service expired first, or the cause of ctx otherwise. fn receives a context that is done once
+// the timeout expires, but isn't interrupted otherwise.
+func withTimeout(ctx context.Context, service Service, fn ContextFunc) Func {
+	return func() error {
+		ctx, cancel := context.WithTimeoutCause(ctx, service.timeout, ServiceTimeoutError(service.name))
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- fn(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// filter passes the error returned by a Func of the Service with the given name through the error filter set with
+// Manager.WithErrorFilter, if any. Context errors bypass the filter.
+func (a *Agent) filter(name string, err error) error {
+	if err == nil || a.opts.errorFilter == nil {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return a.opts.errorFilter(name, err)
+}
+
+// fail records the error, if any, returned by a Func of the Service with the given name during run r. Errors of runs
+// that have ended, such as those of abandoned Services, are dropped.
+func (a *Agent) fail(r *reporter, name string, err error) {
+	if err == nil {
+		return
+	}
+	a.lock.Lock()
+	if !r.ended {
+		a.failures[name] = err
+	}
+	a.lock.Unlock()
+}
+
+// multiError returns the errors recorded for the Services during the current run as a MultiError, in the order in
+// which their priority groups ran in the given direction, and then by name.
+func (a *Agent) multiError(direction state) MultiError {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var merr MultiError
+	for _, priority := range a.priorities(direction) {
+		for _, name := range a.names(priority) {
+			if err, ok := a.failures[name]; ok {
+				merr = append(merr, ServiceError{Service: name, Err: err})
+			}
+		}
+	}
+	return merr
+}
+
+// measure adds the time elapsed since start to the time spent by the Service with the given name during run r, unless
+// the run has ended.
+func (a *Agent) measure(r *reporter, name string, start time.Time) {
+	end := time.Now()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if r.ended {
+		return
+	}
+	a.timings[name] += end.Sub(start)
+	span, ok := a.spans[name]
+	if !ok {
+		span[0] = start
+	}
+	span[1] = end
+	a.spans[name] = span
+}
+
+// ServiceTiming holds the absolute start and end times of a Service during a run, along with its priority.
+type ServiceTiming struct {
+	Service    string
+	Priority   uint16
+	Start, End time.Time
+}
+
+// Timeline returns the start and end times of each Service that ran during the most recent startup or shutdown
+// sequence, ordered by start time, and then by name. For staged Services, the end time includes their promote
+// functions. As the Services of a priority group run concurrently, their intervals may overlap, which makes the
+// timeline suitable for Gantt-style visualizations.
+func (a *Agent) Timeline() []ServiceTiming {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var timeline []ServiceTiming
+	for priority, services := range a.orderedServices {
+		for _, service := range services {
+			if span, ok := a.spans[service.name]; ok {
+				timeline = append(timeline, ServiceTiming{service.name, priority, span[0], span[1]})
+			}
+		}
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		if !timeline[i].Start.Equal(timeline[j].Start) {
+			return timeline[i].Start.Before(timeline[j].Start)
+		}
+		return timeline[i].Service < timeline[j].Service
+	})
+	return timeline
+}
+
+// LevelTiming identifies the slowest Service of a priority group, which gates the completion of the group.
+type LevelTiming struct {
+	Service  string
+	Duration time.Duration
+}
+
+// LevelCriticalPath returns the slowest Service of each priority group during the most recent startup or shutdown
+// sequence, by priority. The time spent by staged Services includes their promote functions. Priority groups that
+// didn't run are left out. If two Services in a group took equally long, the one whose name comes first
+// alphabetically is returned, or the one registered first if configured with Manager.WithStableOrderByRegistration.
+func (a *Agent) LevelCriticalPath() map[uint16]LevelTiming {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	levels := make(map[uint16]LevelTiming)
+	for priority := range a.orderedServices {
+		for _, name := range a.names(priority) {
+			d, ok := a.timings[name]
+			if !ok {
+				continue
+			}
+			if slowest, found := levels[priority]; !found || d > slowest.Duration {
+				levels[priority] = LevelTiming{Service: name, Duration: d}
+			}
+		}
+	}
+	return levels
+}
+
+// CriticalPath returns the chain of Services, linked by Service.After, whose combined durations during the most recent
+// startup or shutdown sequence were the longest, in order of the startup sequence. These are the Services that
+// determined the total time of the sequence, and thereby the ones to optimise in order to speed it up. The time spent
+// by staged Services includes their promote functions. Services that didn't run are left out. If two chains took
+// equally long, the one ending in the Service with the lowest priority is returned, and after that, the one ending in
+// the Service whose name comes first alphabetically, or that was registered first if configured with
+// Manager.WithStableOrderByRegistration. CriticalPath returns nil if no Service has run yet.
+func (a *Agent) CriticalPath() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	total := make(map[string]time.Duration)
+	after := make(map[string]string)
+	var last string
+	for _, priority := range a.priorities(stateUp) {
+		for _, service := range a.sorted(priority) {
+			d, ok := a.timings[service.name]
+			if !ok {
+				continue
+			}
+			if _, ran := total[service.after]; ran {
+				after[service.name] = service.after
+			}
+			total[service.name] = d + total[service.after]
+			if last == "" || total[service.name] > total[last] {
+				last = service.name
+			}
+		}
+	}
+	if last == "" {
+		return nil
+	}
+
+	var path []string
+	for name, ok := last, true; ok; name, ok = after[name] {
+		path = append([]string{name}, path...)
+	}
+	return path
+}
+
+// markStarted records that the Service with the given name was brought up by the startup sequence r, unless the
+// sequence has ended.
+func (a *Agent) markStarted(r *reporter, name string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if r.ended {
+		return
+	}
+	a.started[name] = true
+	a.upOK[name] = true
+}
+
+// Completed returns true if the "up" Func of the Service with the given name ran and succeeded during the most recent
+// startup sequence, even if the sequence failed or was cancelled later on. This allows for conditional logic once the
+// system has booted, such as acting upon a completed migration. Completed keeps returning true if the Service has
+// since been rolled back or shut down, and returns false for Services that were skipped, failed or never ran, as well
+// as before the first startup sequence.
+func (a *Agent) Completed(name string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.upOK[name]
+}
+
+// isStarted returns true if the Service with the given name was brought up by the startup sequence.
+func (a *Agent) isStarted(name string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.started[name]
+}
+
+// LastRunFailures returns the errors returned by Service Funcs during the most recent startup or shutdown sequence,
+// by Service name. The map is empty if every Service Func succeeded, or if no sequence has run yet. The returned map
+// is a copy, and is safe to modify.
+func (a *Agent) LastRunFailures() map[string]error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	failures := make(map[string]error, len(a.failures))
+	for name, err := range a.failures {
+		failures[name] = err
+	}
+	return failures
+}
+
+// AgentSnapshot is a point-in-time copy of the state of an Agent; see Agent.Snapshot.
+type AgentSnapshot struct {
+	Name      string   // Name of the boot sequence.
+	State     string   // Current state: "idle", "up" or "down".
+	Completed []string // Services that have completed successfully during the current run, in order of execution.
+	Done      bool     // Has the current run completed?
+}
+
+// Snapshot returns a consistent copy of the state of the Agent, taken under its lock, which makes it safe to call
+// while a sequence is running, ie. from a status endpoint. Completed lists the Services of the most recent startup or
+// shutdown sequence whose Service Funcs succeeded, ordered by priority in the direction of the sequence, and then by
+// name. The snapshot is a copy, and may be stale as soon as it has been returned.
+func (a *Agent) Snapshot() AgentSnapshot {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	snapshot := AgentSnapshot{Name: a.name, State: a.state.String(), Completed: []string{}, Done: a.isDone}
+	for _, priority := range a.priorities(a.state) {
+		for _, name := range a.names(priority) {
+			_, timed := a.timings[name]
+			_, failed := a.failures[name]
+			if timed && !failed {
+				snapshot.Completed = append(snapshot.Completed, name)
+			}
+		}
+	}
+	return snapshot
+}
+
+// execPromote runs the promote functions of all staged Services with the given priority, reporting each one to r.
+func (a *Agent) execPromote(ctx context.Context, r *reporter, priority uint16) error {
+	var tasks []func() error
+
+	for _, service := range a.group(priority) {
+		if service.promote == nil {
+			continue
+		}
 		service := service
-		grp.Go(func() error {
-			err := service.byState(a.state)() // Execute the Service Func.
-			a.report(Progress{Service: service.name, Err: err})
+		tasks = append(tasks, func() error {
+			start := time.Now()
+			err := service.promote()
+			a.measure(r, service.name, start)
+			a.fail(r, service.name, err)
+			r.report(Progress{Service: service.name, Err: err, Meta: service.meta})
 			return err
 		})
 	}
 
-	done <- grp.Wait()
+	return a.opts.group().Run(ctx, tasks)
 }
 
 // Error returns the error message for the receiver. Error returns an empty string if there is no error.
@@ -1,9 +1,11 @@
 package bootseq
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,13 +22,92 @@ func newIndexUpdater(capacity int) *indexUpdater {
 }
 
 func (i *indexUpdater) progress() func(Progress) {
-	i.lock.Lock()
-	defer i.lock.Unlock()
 	return func(p Progress) {
+		i.lock.Lock()
+		defer i.lock.Unlock()
 		i.actual = append(i.actual, p.Service)
 	}
 }
 
+// serialRunner is a Runner that executes each function immediately, in the order they're passed to Go.
+type serialRunner struct {
+	lock  sync.Mutex
+	calls int
+	err   error
+}
+
+func (r *serialRunner) Go(f func() error) {
+	r.lock.Lock()
+	r.calls++
+	r.lock.Unlock()
+
+	if err := f(); err != nil {
+		r.lock.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.lock.Unlock()
+	}
+}
+
+func (r *serialRunner) Wait() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// serialGroupRunner is a GroupRunner that executes the tasks of each group in order, stopping at the first error.
+type serialGroupRunner struct {
+	groups []int
+}
+
+func (r *serialGroupRunner) Run(_ context.Context, tasks []func() error) error {
+	r.groups = append(r.groups, len(tasks))
+	for _, task := range tasks {
+		if err := task(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failingGroupRunner is a GroupRunner that runs the tasks of each group in order, unless err is set, in which case it
+// returns err without running any of them.
+type failingGroupRunner struct {
+	err error
+}
+
+func (r *failingGroupRunner) Run(_ context.Context, tasks []func() error) error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, task := range tasks {
+		if err := task(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachedGroupRunner is a GroupRunner that starts the tasks of each group in the background and returns right away
+// while detach is set, which leaves them running once the sequence has ended. Otherwise, it runs them in order.
+type detachedGroupRunner struct {
+	detach atomic.Bool
+}
+
+func (r *detachedGroupRunner) Run(_ context.Context, tasks []func() error) error {
+	for _, task := range tasks {
+		if r.detach.Load() {
+			go func() { _ = task() }()
+			continue
+		}
+		if err := task(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var errService = errors.New("service has failed")
 
 // ErrOp (error operation) is a convenience function you can use in place of a
@@ -1,10 +1,17 @@
 package bootseq
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -167,7 +174,7 @@ func TestService(t *testing.T) {
 	t.Run("it panics for unknown state arguments", func(t *testing.T) {
 		defer verifyPanicWithMsg(t, panicUnknownState)
 
-		s := Service{"", 0, ErrOp, ErrOp, ""}
+		s := Service{up: ErrOp, down: ErrOp}
 		fn := s.byState(state(8))
 		_ = fn()
 
@@ -175,7 +182,7 @@ func TestService(t *testing.T) {
 	})
 
 	t.Run("it returns the correct function by state", func(t *testing.T) {
-		s := Service{"", 0, NoOp, ErrOp, ""}
+		s := Service{up: NoOp, down: ErrOp}
 		fn := s.byState(stateUp)
 		err := fn()
 		verifyNilErr(t, err)
@@ -188,7 +195,7 @@ func TestService(t *testing.T) {
 	})
 
 	t.Run("it sets correct reference name", func(t *testing.T) {
-		s := Service{"", 0, NoOp, ErrOp, ""}
+		s := Service{up: NoOp, down: ErrOp}
 		s.After("other")
 		if s.after != "other" {
 			t.Fatalf("expected reference to %q, got %q", "other", s.after)
@@ -228,6 +235,16 @@ func TestManagerAdd(t *testing.T) {
 		}
 	})
 
+	t.Run("replaces the whole service when re-registering it", func(t *testing.T) {
+		mgr := New("Start")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).Tag("web").After("one")
+		mgr.Register("two", ErrOp, ErrOp)
+		if srvc := mgr.services["two"]; srvc.after != "" || len(srvc.tags) != 0 {
+			t.Fatalf("expected a new service, got one after %q with tags %v", srvc.after, srvc.tags)
+		}
+	})
+
 	t.Run("panics if more than 65535 services are registered", func(t *testing.T) {
 		mgr := New("Big one")
 
@@ -235,11 +252,263 @@ func TestManagerAdd(t *testing.T) {
 			mgr.Register("Service #"+strconv.Itoa(i), NoOp, NoOp)
 		}
 
-		defer verifyPanicWithMsg(t, panicServiceLimit)
+		defer verifyPanicWithMsg(t, "reached limit of max 65535 services")
 		mgr.Register("Service #65536", NoOp, NoOp)
 
 		t.Fatal("expected to panic on the 65536th service")
 	})
+
+	t.Run("panics if more services are registered than the configured limit", func(t *testing.T) {
+		mgr := New("Small one").WithServiceLimit(2)
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp) // Replacing a service is always allowed.
+
+		defer verifyPanicWithMsg(t, "reached limit of max 2 services")
+		mgr.Register("three", NoOp, NoOp)
+
+		t.Fatal("expected to panic on the third service")
+	})
+}
+
+func TestManagerTryRegister(t *testing.T) {
+	t.Run("it registers services with non-nil funcs", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		srvc, err := mgr.TryRegister("one", NoOp, NoOp)
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "one", srvc.name)
+		verifyCountEq(t, uint32(mgr.ServiceCount()), 1)
+	})
+
+	t.Run("it rejects nil funcs", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		srvc, err := mgr.TryRegister("one", NoOp, nil)
+		verifyErrorType(t, err, NilFuncError("one"))
+		if srvc != nil {
+			t.Fatalf("expected a nil Service, got %v", srvc)
+		}
+		_, err = mgr.TryRegister("two", nil, NoOp)
+		verifyErrorType(t, err, NilFuncError("two"))
+		verifyCountEq(t, uint32(mgr.ServiceCount()), 0)
+	})
+
+	t.Run("it rejects duplicate names", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		srvc, err := mgr.TryRegister("one", ErrOp, ErrOp)
+		verifyErrorType(t, err, DuplicateServiceError("one"))
+		if srvc != nil {
+			t.Fatalf("expected a nil Service, got %v", srvc)
+		}
+		verifyCountEq(t, uint32(mgr.ServiceCount()), 1)
+		verifyNilErr(t, mgr.services["one"].up())
+	})
+}
+
+func TestManagerRegisterN(t *testing.T) {
+	t.Run("it registers numbered services", func(t *testing.T) {
+		mgr := New("Fan-out boot sequence")
+		mgr.Register("queue", NoOp, NoOp)
+		workers := mgr.RegisterN("worker", 3, NoOp, NoOp)
+		verifyCountEq(t, uint32(len(workers)), 3)
+		for _, worker := range workers {
+			worker.After("queue")
+		}
+		workers[2].After("worker-1")
+
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(queue) > (worker-1 : worker-2) > (worker-3)", agent.String())
+	})
+
+	t.Run("it registers nothing for non-positive counts", func(t *testing.T) {
+		mgr := New("Fan-out boot sequence")
+		verifyCountEq(t, uint32(len(mgr.RegisterN("worker", 0, NoOp, NoOp))), 0)
+		verifyCountEq(t, uint32(len(mgr.RegisterN("worker", -1, NoOp, NoOp))), 0)
+		verifyCountEq(t, uint32(mgr.ServiceCount()), 0)
+	})
+}
+
+func TestManagerRegisterStrict(t *testing.T) {
+	t.Run("it accepts registered services", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		b := mgr.RegisterStrict("two", NoOp, NoOp).After("one")
+		verifyNilErr(t, b.Err())
+		verifyStringEquals(t, "one", b.Service().after)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one) > (two)", agent.String())
+	})
+
+	t.Run("it rejects forward references", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		b := mgr.RegisterStrict("two", NoOp, NoOp).After("one")
+		if !errors.Is(b.Err(), UnregisteredServiceError("one")) {
+			t.Fatalf("expected an UnregisteredServiceError, got %v", b.Err())
+		}
+		_, err := mgr.Agent()
+		verifyErrorType(t, err, UnregisteredServiceError("one"))
+	})
+
+	t.Run("it accumulates errors", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		err := mgr.RegisterStrict("two", NoOp, NoOp).After("two").After("one").Err()
+		if !errors.Is(err, SelfReferenceError("two")) || !errors.Is(err, UnregisteredServiceError("one")) {
+			t.Fatalf("expected both errors, got %v", err)
+		}
+	})
+}
+
+func TestManagerMustRegister(t *testing.T) {
+	t.Run("it registers services", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		mgr.MustRegister("one", NoOp, NoOp)
+		mgr.MustRegister("two", NoOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one) > (two)", agent.String())
+	})
+
+	t.Run("it panics on nil funcs", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		defer verifyPanicWithMsg(t, NilFuncError("one").Error())
+		mgr.MustRegister("one", nil, NoOp)
+		t.Fatal("expected to panic")
+	})
+
+	t.Run("it panics on duplicate names", func(t *testing.T) {
+		mgr := New("Strict boot sequence")
+		mgr.MustRegister("one", NoOp, NoOp)
+		defer verifyPanicWithMsg(t, DuplicateServiceError("one").Error())
+		mgr.MustRegister("one", NoOp, NoOp)
+		t.Fatal("expected to panic")
+	})
+}
+
+func TestManagerFreeze(t *testing.T) {
+	t.Run("it keeps registered services", func(t *testing.T) {
+		mgr := New("Frozen boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		frozen := mgr.Freeze()
+		agent, err := frozen.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one) > (two)", agent.String())
+	})
+
+	t.Run("it rejects registrations", func(t *testing.T) {
+		mgr := New("Frozen boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		frozen := mgr.Freeze()
+		_, err := frozen.TryRegister("two", NoOp, NoOp)
+		verifyErrorType(t, err, SealedManagerError("two"))
+		verifyStringEquals(t, `cannot change frozen manager: "two"`, err.Error())
+		frozen.Register("three", NoOp, NoOp).After("one")
+		frozen.Register("one", PanicOp, PanicOp)
+		verifyCountEq(t, 1, uint32(frozen.ServiceCount()))
+		verifyErrorType(t, frozen.Validate(), SealedManagerError("three"))
+		_, err = frozen.Agent()
+		verifyErrorType(t, err, SealedManagerError("three"))
+	})
+
+	t.Run("it rejects changes to settings and services", func(t *testing.T) {
+		mgr := New("Frozen boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		frozen := mgr.Freeze()
+		for _, srvc := range frozen.All() {
+			srvc.After("missing") // A copy, which never affects the Manager.
+		}
+		verifyNilErr(t, frozen.Validate())
+
+		frozen.WithStrategy(Serial).OneOf("pair", []string{"one", "two"}).ResolveAfter(nil)
+		verifyErrorType(t, frozen.Validate(), SealedManagerError("WithStrategy"))
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one : two)", agent.String())
+	})
+
+	t.Run("it is unaffected by later changes to the original", func(t *testing.T) {
+		mgr := New("Frozen boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		frozen := mgr.Freeze()
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("one", ErrOp, NoOp)
+		agent, err := frozen.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one)", agent.String())
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+	})
+}
+
+func TestManagerSealOnAgent(t *testing.T) {
+	t.Run("it rejects registrations after Agent", func(t *testing.T) {
+		mgr := New("Sealed boot sequence").SealOnAgent()
+		mgr.Register("one", NoOp, NoOp)
+		_, err := mgr.Agent()
+		verifyNilErr(t, err)
+		_, err = mgr.TryRegister("two", NoOp, NoOp)
+		verifyErrorType(t, err, SealedManagerError("two"))
+		mgr.Register("one", ErrOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyErrorType(t, err, SealedManagerError("one"))
+		if agent != nil {
+			t.Fatal("expected no agent")
+		}
+	})
+
+	t.Run("it rejects changes to services registered before Agent", func(t *testing.T) {
+		mgr := New("Sealed boot sequence").SealOnAgent()
+		one := mgr.Register("one", NoOp, NoOp)
+		two := mgr.Register("two", NoOp, NoOp)
+		_, err := mgr.Agent()
+		verifyNilErr(t, err)
+		two.After("one")
+		one.WithTimeout(time.Millisecond).Tag("late")
+		verifyStringEquals(t, "", two.Predecessor())
+		verifyStringsEqual(t, nil, one.Tags())
+		verifyErrorType(t, mgr.Validate(), SealedManagerError("two"))
+	})
+
+	t.Run("it stays open if Agent fails", func(t *testing.T) {
+		mgr := New("Sealed boot sequence").SealOnAgent()
+		mgr.Register("two", NoOp, NoOp).After("one")
+		_, err := mgr.Agent()
+		verifyErrorType(t, err, UnregisteredServiceError("one"))
+		_, err = mgr.TryRegister("one", NoOp, NoOp)
+		verifyNilErr(t, err)
+	})
+
+	t.Run("it rejects registrations after AgentForTags", func(t *testing.T) {
+		mgr := New("Sealed boot sequence").SealOnAgent()
+		mgr.Register("one", NoOp, NoOp).Tag("web")
+		_, err := mgr.AgentForTags("web")
+		verifyNilErr(t, err)
+		_, err = mgr.TryRegister("two", NoOp, NoOp)
+		verifyErrorType(t, err, SealedManagerError("two"))
+	})
+
+	t.Run("it is disabled by default", func(t *testing.T) {
+		mgr := New("Open boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		_, err := mgr.Agent()
+		verifyNilErr(t, err)
+		_, err = mgr.TryRegister("two", NoOp, NoOp)
+		verifyNilErr(t, err)
+	})
+}
+
+func TestAgentIndependentOfLaterRegister(t *testing.T) {
+	mgr := New("Independent boot sequence")
+	mgr.Register("one", NoOp, NoOp)
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+	mgr.Register("one", ErrOp, ErrOp)
+	mgr.Register("two", NoOp, NoOp)
+	verifyStringEquals(t, "(one)", agent.String())
+	verifyNilErr(t, agent.Up(context.Background(), nil))
+	verifyNilErr(t, agent.Down(context.Background(), nil))
 }
 
 func TestManagerValidate(t *testing.T) {
@@ -247,6 +516,15 @@ func TestManagerValidate(t *testing.T) {
 		mgr := New("Empty")
 		err := mgr.Validate()
 		verifyErrorType(t, err, EmptySequenceError("Empty"))
+		verifyStringEquals(t, `no services registered in boot sequence "Empty"`, err.Error())
+	})
+
+	t.Run("returns an error for an empty name", func(t *testing.T) {
+		mgr := New("")
+		mgr.Register("one", NoOp, NoOp)
+		err := mgr.Validate()
+		verifyErrorType(t, err, ErrEmptyName)
+		verifyStringEquals(t, "boot sequence has no name", err.Error())
 	})
 
 	t.Run("returns an error for a service with nil Funcs", func(t *testing.T) {
@@ -256,6 +534,13 @@ func TestManagerValidate(t *testing.T) {
 		verifyErrorType(t, err, NilFuncError("oops"))
 	})
 
+	t.Run("returns an error for a service with a reserved name", func(t *testing.T) {
+		mgr := New("Invalid #0")
+		mgr.Register(DoneService, NoOp, NoOp)
+		err := mgr.Validate()
+		verifyErrorType(t, err, ReservedNameError(DoneService))
+	})
+
 	t.Run("returns an error for a self-referencing service", func(t *testing.T) {
 		mgr := New("Invalid #2")
 		mgr.Register("selfie", NoOp, NoOp).After("selfie")
@@ -285,6 +570,17 @@ func TestManagerValidate(t *testing.T) {
 		}
 	})
 
+	t.Run("returns an error when there are indirect cyclic references", func(t *testing.T) {
+		mgr := New("Very Invalid Boot Sequence")
+		mgr.Register("first_service", NoOp, NoOp).After("third_service")
+		mgr.Register("second_service", NoOp, NoOp).After("first_service")
+		mgr.Register("third_service", NoOp, NoOp).After("second_service")
+		err := mgr.Validate()
+		if _, ok := err.(CyclicReferenceError); !ok {
+			t.Fatalf("expected a CyclicReferenceError, got %v", err)
+		}
+	})
+
 	t.Run("succeeds when registering same service twice", func(t *testing.T) {
 		mgr := New("Acceptable Boot Sequence")
 		mgr.Register("duplicate_service", NoOp, NoOp)
@@ -326,6 +622,225 @@ func TestManagerValidate(t *testing.T) {
 	})
 }
 
+func TestManagerResolveAfter(t *testing.T) {
+	t.Run("it orders services by the resolved references", func(t *testing.T) {
+		var calls []string
+		mgr := New("Late bound boot sequence").ResolveAfter(func(name string, names []string) []string {
+			calls = append(calls, name)
+			verifyStringsEqual(t, []string{"api", "db", "plugin"}, names)
+			if name == "plugin" {
+				return []string{"api"}
+			}
+			if name == "api" {
+				return []string{"db"}
+			}
+			return nil
+		})
+		mgr.Register("plugin", NoOp, NoOp)
+		mgr.Register("api", NoOp, NoOp).After("plugin") // Replaced by the resolved reference.
+		mgr.Register("db", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"api", "db", "plugin"}, calls)
+		verifyStringEquals(t, "(db) > (api) > (plugin)", agent.String())
+		verifyStringEquals(t, "plugin", mgr.services["api"].after)
+	})
+
+	t.Run("it returns an error for more than one resolved reference", func(t *testing.T) {
+		mgr := New("Late bound boot sequence").ResolveAfter(func(name string, names []string) []string {
+			if name == "two" {
+				return []string{"one", "three"}
+			}
+			return nil
+		})
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("three", NoOp, NoOp)
+		err := mgr.Validate()
+		verifyErrorType(t, err, AmbiguousReferenceError("two"))
+	})
+
+	t.Run("it validates the resolved references", func(t *testing.T) {
+		mgr := New("Late bound boot sequence").ResolveAfter(func(name string, names []string) []string {
+			if name == "one" {
+				return []string{"two"}
+			}
+			return []string{"one"}
+		})
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		_, err := mgr.Agent()
+		if _, ok := err.(CyclicReferenceError); !ok {
+			t.Fatalf("expected a CyclicReferenceError, got %v", err)
+		}
+	})
+}
+
+func TestManagerAgentForTags(t *testing.T) {
+	t.Run("it includes tagged services and their dependencies", func(t *testing.T) {
+		mgr := New("Tagged boot sequence")
+		mgr.Register("config", NoOp, NoOp)
+		mgr.Register("dns", NoOp, NoOp).Tag("network").After("config")
+		mgr.Register("disk", NoOp, NoOp).Tag("storage")
+		mgr.Register("metrics", NoOp, NoOp).Tag("observability", "network").After("dns")
+		mgr.Register("api", NoOp, NoOp).After("metrics")
+		agent, err := mgr.AgentForTags("network")
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(config) > (dns) > (metrics)", agent.String())
+
+		agent, err = mgr.AgentForTags("storage", "observability")
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(config : disk) > (dns) > (metrics)", agent.String())
+	})
+
+	t.Run("it returns an error for unknown tags", func(t *testing.T) {
+		mgr := New("Tagged boot sequence")
+		mgr.Register("config", NoOp, NoOp).Tag("core")
+		_, err := mgr.AgentForTags("network", "storage")
+		verifyErrorType(t, err, UnknownTagError("network, storage"))
+	})
+}
+
+func TestManagerGraph(t *testing.T) {
+	t.Run("it returns the declared dependencies", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		mgr.Register("three", NoOp, NoOp).After("missing")
+
+		graph := mgr.Graph()
+		if len(graph) != 3 {
+			t.Fatalf("expected 3 services in graph, got %d", len(graph))
+		}
+		verifyStringsEqual(t, []string{}, graph["one"])
+		verifyStringsEqual(t, []string{"one"}, graph["two"])
+		verifyStringsEqual(t, []string{"missing"}, graph["three"])
+	})
+
+	t.Run("it returns a copy", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+
+		graph := mgr.Graph()
+		graph["two"][0] = "three"
+		delete(graph, "one")
+		verifyStringsEqual(t, []string{"one"}, mgr.Graph()["two"])
+		if _, ok := mgr.Graph()["one"]; !ok {
+			t.Fatal("expected service one to remain in graph")
+		}
+	})
+}
+
+func TestManagerSamePriorityPairs(t *testing.T) {
+	t.Run("it returns sorted pairs per priority", func(t *testing.T) {
+		mgr := New("Paired boot sequence")
+		mgr.Register("db", NoOp, NoOp)
+		mgr.Register("config", NoOp, NoOp)
+		mgr.Register("cache", NoOp, NoOp).After("db")
+		mgr.Register("api", NoOp, NoOp).After("config")
+		mgr.Register("auth", NoOp, NoOp).After("config")
+		mgr.Register("web", NoOp, NoOp).After("api")
+
+		var actual []string
+		for _, pair := range mgr.SamePriorityPairs() {
+			actual = append(actual, pair[0]+":"+pair[1])
+		}
+		verifyStringEquals(t, "api:auth,api:cache,auth:cache,config:db", strings.Join(actual, ","))
+	})
+
+	t.Run("it returns nil for invalid managers", func(t *testing.T) {
+		mgr := New("Paired boot sequence")
+		mgr.Register("one", NoOp, NoOp).After("nobody")
+		if pairs := mgr.SamePriorityPairs(); pairs != nil {
+			t.Fatalf("expected nil, got %v", pairs)
+		}
+	})
+}
+
+func TestLoadManager(t *testing.T) {
+	t.Run("it registers services with placeholder funcs", func(t *testing.T) {
+		doc := `{"name": "Loaded", "services": [{"name": "one", "after": []}, {"name": "two", "after": ["one"]}]}`
+		mgr, err := LoadManager(strings.NewReader(doc))
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "Loaded", mgr.name)
+		verifyIdenticalSets(t, []string{"one", "two"}, mgr.ServiceNames())
+
+		err = mgr.Validate()
+		if _, ok := err.(NilFuncError); !ok {
+			t.Fatalf("expected a NilFuncError, got %v", err)
+		}
+
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one) > (two)", agent.String())
+	})
+
+	t.Run("it replaces loaded services that are registered twice", func(t *testing.T) {
+		doc := `{"name": "Loaded", "services": [{"name": "one", "after": []}, {"name": "two", "after": ["one"]}]}`
+		mgr, err := LoadManager(strings.NewReader(doc))
+		verifyNilErr(t, err)
+
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		verifyStringEquals(t, "", mgr.services["two"].after)
+	})
+
+	t.Run("it returns an error for duplicate services", func(t *testing.T) {
+		doc := `{"name": "Loaded", "services": [{"name": "one"}, {"name": "one"}]}`
+		_, err := LoadManager(strings.NewReader(doc))
+		verifyErrorType(t, err, InvalidPlanError("duplicate service one"))
+	})
+
+	t.Run("it returns an error for multiple dependencies", func(t *testing.T) {
+		doc := `{"name": "Loaded", "services": [{"name": "one", "after": ["two", "three"]}]}`
+		_, err := LoadManager(strings.NewReader(doc))
+		verifyErrorType(t, err, InvalidPlanError("service one must run after at most one service"))
+	})
+
+	t.Run("it returns an error for malformed documents", func(t *testing.T) {
+		_, err := LoadManager(strings.NewReader(`{"name": "Loaded", "services": [{"title": "one"}]}`))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestManagerSave(t *testing.T) {
+	mgr := New("Saved")
+	mgr.Register("two", NoOp, NoOp).After("one")
+	mgr.Register("one", NoOp, NoOp)
+
+	var buf bytes.Buffer
+	err := mgr.Save(&buf)
+	verifyNilErr(t, err)
+
+	expected := `{
+	"name": "Saved",
+	"services": [
+		{
+			"name": "one",
+			"after": []
+		},
+		{
+			"name": "two",
+			"after": [
+				"one"
+			]
+		}
+	]
+}
+`
+	verifyStringEquals(t, expected, buf.String())
+
+	loaded, err := LoadManager(&buf)
+	verifyNilErr(t, err)
+	verifyStringEquals(t, "one", loaded.services["two"].after)
+}
+
 func TestManagerServiceCount(t *testing.T) {
 	mgr := New("A Boot Sequence")
 	mgr.Register("one", NoOp, NoOp)
@@ -343,6 +858,100 @@ func TestManagerServiceCount(t *testing.T) {
 	verifyCountEq(t, 5, uint32(mgr.ServiceCount()))
 }
 
+func TestManagerAll(t *testing.T) {
+	t.Run("it iterates over copies of the services", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("db", NoOp, NoOp).Tag("storage")
+		mgr.Register("api", NoOp, NoOp).After("db")
+
+		var names, lines []string
+		for name, srvc := range mgr.All() {
+			names = append(names, name)
+			lines = append(lines, fmt.Sprintf("%s<%s>%d%v", srvc.Name(), srvc.Predecessor(), srvc.Priority(), srvc.Tags()))
+			srvc.After("nothing") // Doesn't affect the Manager.
+		}
+		verifyStringEquals(t, "api,db", strings.Join(names, ","))
+		verifyStringEquals(t, "api<db>2[],db<>1[storage]", strings.Join(lines, ","))
+		verifyNilErr(t, mgr.Validate())
+	})
+
+	t.Run("it leaves priorities unresolved if the manager doesn't validate", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("api", NoOp, NoOp).After("db")
+		for _, srvc := range mgr.All() {
+			verifyCountEq(t, uint32(srvc.Priority()), 0)
+		}
+	})
+
+	t.Run("it stops early", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.RegisterN("worker", 3, NoOp, NoOp)
+		count := 0
+		for range mgr.All() {
+			count++
+			break
+		}
+		verifyCountEq(t, uint32(count), 1)
+	})
+}
+
+func TestManagerDepth(t *testing.T) {
+	cases := []struct {
+		name     string
+		after    map[string]string
+		expected uint16
+	}{
+		{"base case", map[string]string{"one": ""}, 1},
+		{"simple case", map[string]string{"one": "", "two": ""}, 1},
+		{
+			"stair case",
+			map[string]string{"one": "", "two": "one", "three": "two", "four": "three", "five": "four", "six": "five"},
+			6,
+		},
+		{
+			"even case",
+			map[string]string{"one": "", "two": "", "three": "", "four": "", "five": "", "six": ""},
+			1,
+		},
+		{
+			"mixed case",
+			map[string]string{"one": "", "two": "one", "three": "two", "four": "two", "five": "four", "six": "five"},
+			5,
+		},
+		{
+			"complex case",
+			map[string]string{
+				"one": "", "two": "", "three": "", "four": "three", "five": "two",
+				"six": "five", "seven": "five", "eight": "seven", "nine": "eight", "ten": "nine",
+			},
+			6,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := New("Deep boot sequence")
+			for name, after := range tt.after {
+				mgr.Register(name, NoOp, NoOp).After(after)
+			}
+			verifyCountEq(t, uint32(tt.expected), uint32(mgr.Depth()))
+		})
+	}
+
+	t.Run("counts exclusive services", func(t *testing.T) {
+		mgr := New("Deep boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).Exclusive()
+		verifyCountEq(t, 2, uint32(mgr.Depth()))
+	})
+
+	t.Run("returns zero for invalid sequences", func(t *testing.T) {
+		mgr := New("Deep boot sequence")
+		mgr.Register("one", NoOp, NoOp).After("two")
+		verifyCountEq(t, 0, uint32(mgr.Depth()))
+	})
+}
+
 func TestAgentNilFunc(t *testing.T) {
 	mgr := New("Nil Func")
 	mgr.Register("one", nil, nil)
@@ -360,279 +969,3017 @@ func TestAgent(t *testing.T) {
 	mgr.Register("two", NoOp, NoOp)
 	mgr.Register("three", NoOp, NoOp)
 
-	updater1 := newIndexUpdater(4)
-	updater2 := newIndexUpdater(5)
+	updater1 := newIndexUpdater(4)
+	updater2 := newIndexUpdater(5)
+
+	// First agent.
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+	err = agent.Up(context.Background(), updater1.progress())
+	verifyNilErr(t, err)
+	verifyStringsEqual(t, []string{"one", "two", "three", DoneService}, updater1.actual)
+
+	// Second agent.
+	mgr.Register("four", NoOp, NoOp)
+	agent, err = mgr.Agent()
+	verifyNilErr(t, err)
+	err = agent.Up(context.Background(), updater2.progress())
+	verifyNilErr(t, err)
+	verifyStringsEqual(t, []string{"one", "two", "three", "four", DoneService}, updater2.actual)
+}
+
+func TestAgentRunner(t *testing.T) {
+	t.Run("it uses the runner provided to the manager", func(t *testing.T) {
+		runners := make([]*serialRunner, 0, 2)
+		mgr := New("Custom runner").WithRunner(func() Runner {
+			r := &serialRunner{}
+			runners = append(runners, r)
+			return r
+		})
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("three", NoOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+
+		verifyCountEq(t, uint32(len(runners)), 2)
+		verifyCountEq(t, uint32(runners[0].calls), 2)
+		verifyCountEq(t, uint32(runners[1].calls), 1)
+	})
+
+	t.Run("it returns errors from the runner", func(t *testing.T) {
+		mgr := New("Custom runner").WithRunner(func() Runner { return &serialRunner{} })
+		mgr.Register("one", ErrOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+	})
+}
+
+func TestAgentGroupRunner(t *testing.T) {
+	t.Run("it uses the group runner provided to the manager", func(t *testing.T) {
+		runner := &serialGroupRunner{}
+		mgr := New("Custom group runner").WithGroupRunner(runner)
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("three", NoOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+
+		verifyCountEq(t, uint32(len(runner.groups)), 2)
+		verifyCountEq(t, uint32(runner.groups[0]), 2)
+		verifyCountEq(t, uint32(runner.groups[1]), 1)
+	})
+
+	t.Run("it takes precedence over the runner", func(t *testing.T) {
+		runner := &serialGroupRunner{}
+		mgr := New("Custom group runner").WithGroupRunner(runner).WithRunner(func() Runner {
+			panic("the runner should never be used")
+		})
+		mgr.Register("one", ErrOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		verifyCountEq(t, uint32(len(runner.groups)), 1)
+	})
+}
+
+func TestManagerWithMaxConcurrency(t *testing.T) {
+	t.Run("it runs services one at a time in alphabetical order", func(t *testing.T) {
+		mgr := New("Serial boot sequence").WithMaxConcurrency(1).WithRunner(func() Runner {
+			panic("the runner should never be used")
+		})
+		mgr.Register("charlie", NoOp, NoOp)
+		mgr.Register("alpha", NoOp, NoOp)
+		mgr.Register("bravo", NoOp, NoOp)
+		mgr.Register("delta", NoOp, NoOp).After("alpha")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var names []string
+		err = agent.Up(context.Background(), func(p Progress) {
+			names = append(names, p.Service)
+		})
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "alpha,bravo,charlie,delta,"+DoneService, strings.Join(names, ","))
+	})
+
+	t.Run("it limits the number of services running at once", func(t *testing.T) {
+		var running, peak int32
+		track := func() error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}
+
+		mgr := New("Throttled boot sequence").WithMaxConcurrency(2)
+		for _, name := range []string{"one", "two", "three", "four", "five"} {
+			mgr.Register(name, track, NoOp)
+		}
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		if peak != 2 {
+			t.Fatalf("expected at most 2 services running at once, got %d", peak)
+		}
+	})
+}
+
+func TestServiceWeight(t *testing.T) {
+	var running, peak int32
+	track := func(weight int32) Func {
+		return func() error {
+			n := atomic.AddInt32(&running, weight)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -weight)
+			return nil
+		}
+	}
+
+	t.Run("it limits the total weight of services running at once", func(t *testing.T) {
+		running, peak = 0, 0
+		mgr := New("Weighted boot sequence").WithMaxConcurrency(10).WithDeterministicWithinLevel()
+		mgr.Register("heavy-1", track(5), NoOp).Weight(5)
+		mgr.Register("heavy-2", track(5), NoOp).Weight(5)
+		mgr.Register("heavy-3", track(5), NoOp).Weight(5)
+		mgr.Register("light-1", track(2), NoOp).Weight(2)
+		mgr.Register("light-2", track(2), NoOp).Weight(2)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		if peak != 10 {
+			t.Fatalf("expected a peak weight of 10, got %d", peak)
+		}
+	})
+
+	t.Run("it runs services that exceed the limit on their own", func(t *testing.T) {
+		running, peak = 0, 0
+		mgr := New("Weighted boot sequence").WithMaxConcurrency(2)
+		mgr.Register("heavy", track(2), NoOp).Weight(5) // Takes up the whole limit.
+		mgr.Register("light-1", track(1), NoOp)
+		mgr.Register("light-2", track(1), NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		if peak > 2 {
+			t.Fatalf("expected a peak weight of at most 2, got %d", peak)
+		}
+	})
+}
+
+func TestManagerWithErrorFilter(t *testing.T) {
+	errExists := errors.New("already exists")
+	exists := func() error { return errExists }
+	ignoreExists := func(_ string, err error) error {
+		if errors.Is(err, errExists) {
+			return nil
+		}
+		return fmt.Errorf("fatal: %w", err)
+	}
+
+	t.Run("it treats filtered errors as success", func(t *testing.T) {
+		mgr := New("Boot it!").WithErrorFilter(ignoreExists)
+		mgr.Register("one", exists, NoOp)
+		mgr.Register("two", NoOp, exists).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var reported []error
+		err = agent.Up(context.Background(), func(p Progress) {
+			if p.Service == "one" {
+				reported = append(reported, p.Err)
+			}
+		})
+		verifyNilErr(t, err)
+		if len(reported) != 1 || reported[0] != errExists {
+			t.Fatalf("expected the original error to be reported, got %v", reported)
+		}
+		if len(agent.LastRunFailures()) != 0 {
+			t.Fatalf("expected no failures, got %v", agent.LastRunFailures())
+		}
+
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+	})
+
+	t.Run("it propagates the errors returned by the filter", func(t *testing.T) {
+		mgr := New("Boot it!").WithErrorFilter(ignoreExists)
+		mgr.Register("one", ErrOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		if !errors.Is(err, errService) || !strings.HasPrefix(err.Error(), "fatal: ") {
+			t.Fatalf("expected the wrapped error, got %v", err)
+		}
+	})
+
+	t.Run("it doesn't filter context errors", func(t *testing.T) {
+		mgr := New("Boot it!").WithErrorFilter(func(string, error) error {
+			return nil
+		})
+		mgr.Register("one", func() error { return context.DeadlineExceeded }, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestManagerOnRetry(t *testing.T) {
+	t.Run("it retries failed services and reports each retry", func(t *testing.T) {
+		var calls int32
+		flaky := func() error {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				return errService
+			}
+			return nil
+		}
+
+		var lock sync.Mutex
+		var attempts []string
+		mgr := New("Boot it!").OnRetry(func(name string, attempt int, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			attempts = append(attempts, name+":"+strconv.Itoa(attempt)+":"+err.Error())
+		})
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("flaky", flaky, NoOp).WithRetry(5, time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(3)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyIdenticalSets(t, []string{"one", "flaky", DoneService}, updater.actual)
+		verifyStringsEqual(t, []string{"flaky:1:" + errService.Error(), "flaky:2:" + errService.Error()}, attempts)
+	})
+
+	t.Run("it fails once the retries are exhausted", func(t *testing.T) {
+		var retries int32
+		mgr := New("Boot it!").OnRetry(func(string, int, error) {
+			atomic.AddInt32(&retries, 1)
+		})
+		mgr.Register("one", ErrOp, NoOp).WithRetry(2, time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		verifyCountEq(t, uint32(atomic.LoadInt32(&retries)), 2)
+	})
+
+	t.Run("it doesn't retry shutdown", func(t *testing.T) {
+		var retries int32
+		mgr := New("Boot it!").OnRetry(func(string, int, error) {
+			atomic.AddInt32(&retries, 1)
+		})
+		mgr.Register("one", NoOp, ErrOp).WithRetry(2, time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		err = agent.Down(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		verifyCountEq(t, uint32(atomic.LoadInt32(&retries)), 0)
+	})
+
+	t.Run("it stops retrying when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		mgr := New("Boot it!").OnRetry(func(string, int, error) {
+			cancel()
+		})
+		mgr.Register("one", ErrOp, NoOp).WithRetry(100, time.Hour)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(ctx, nil)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestManagerWithStrategy(t *testing.T) {
+	newManager := func() *Manager {
+		mgr := New("Strategic boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		mgr.Register("three", NoOp, NoOp)
+		mgr.Register("four", NoOp, NoOp).After("one")
+		return mgr
+	}
+
+	t.Run("it runs services concurrently by default", func(t *testing.T) {
+		mgr := newManager()
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one : three) > (four : two)", agent.String())
+		verifyCountEq(t, 2, uint32(mgr.Depth()))
+	})
+
+	t.Run("it runs services one at a time", func(t *testing.T) {
+		mgr := newManager().WithStrategy(Serial)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(one) > (three) > (four) > (two)", agent.String())
+		verifyCountEq(t, 4, uint32(mgr.Depth()))
+		if pairs := mgr.SamePriorityPairs(); len(pairs) != 0 {
+			t.Fatalf("expected no services at the same priority, got %v", pairs)
+		}
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyNilErr(t, agent.Down(context.Background(), nil))
+	})
+
+	t.Run("it restores the default", func(t *testing.T) {
+		mgr := newManager().WithStrategy(Serial).WithStrategy(nil)
+		verifyCountEq(t, 2, uint32(mgr.Depth()))
+	})
+}
+
+func TestManagerWithDeterministicWithinLevel(t *testing.T) {
+	mgr := New("Boot it!").WithDeterministicWithinLevel().WithGroupRunner(&serialGroupRunner{})
+	for _, name := range []string{"kappa", "delta", "omega", "alpha", "sigma"} {
+		mgr.Register(name, NoOp, NoOp)
+	}
+
+	for i := 0; i < 3; i++ {
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		updater := newIndexUpdater(6)
+		verifyNilErr(t, agent.Up(context.Background(), updater.progress()))
+		verifyStringEquals(t, "alpha,delta,kappa,omega,sigma,<done>", strings.Join(updater.actual, ","))
+	}
+}
+
+func TestManagerWithStableOrderByRegistration(t *testing.T) {
+	register := func(mgr *Manager) {
+		mgr.Register("zeta", NoOp, NoOp)
+		mgr.Register("alpha", NoOp, NoOp)
+		mgr.Register("mu", NoOp, NoOp)
+		mgr.Register("beta", NoOp, NoOp).After("zeta")
+		mgr.Register("alpha", NoOp, NoOp) // Replacing a Service keeps its position.
+	}
+
+	t.Run("it sorts alphabetically by default", func(t *testing.T) {
+		mgr := New("Boot it!")
+		register(mgr)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyStringEquals(t, "(alpha : mu : zeta) > (beta)", agent.String())
+		verifyStringsEqual(t, []string{"alpha", "mu", "zeta", "beta"}, agent.Linearize())
+	})
+
+	t.Run("it sorts by registration order", func(t *testing.T) {
+		mgr := New("Boot it!").WithStableOrderByRegistration()
+		register(mgr)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyStringEquals(t, "(zeta : alpha : mu) > (beta)", agent.String())
+		verifyStringsEqual(t, []string{"zeta", "alpha", "mu", "beta"}, agent.Linearize())
+		verifyStringsEqual(t, []string{"zeta", "mu"}, agent.ConcurrentWith("alpha"))
+	})
+
+	t.Run("it starts services in registration order within each level", func(t *testing.T) {
+		mgr := New("Boot it!").WithStableOrderByRegistration().WithDeterministicWithinLevel()
+		mgr.WithGroupRunner(&serialGroupRunner{})
+		register(mgr)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(5)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "zeta,alpha,mu,beta,<done>", strings.Join(updater.actual, ","))
+	})
+
+	t.Run("it runs serial services in registration order", func(t *testing.T) {
+		mgr := New("Boot it!").WithStableOrderByRegistration().WithMaxConcurrency(1)
+		register(mgr)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(5)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"zeta", "alpha", "mu", "beta", DoneService}, updater.actual)
+	})
+}
+
+func TestManagerBeforeEach(t *testing.T) {
+	var (
+		lock   sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		lock.Lock()
+		events = append(events, event)
+		lock.Unlock()
+	}
+
+	mgr := New("Observed boot sequence").BeforeEach(func(name string, phase string) {
+		record(phase + " " + name)
+	})
+	mgr.Register("one", NoOp, NoOp)
+	mgr.Register("two", NoOp, NoOp).After("one")
+	mgr.Register("three", NoOp, NoOp).After("one")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	progress := func(p Progress) { record(p.Service) }
+
+	err = agent.Up(context.Background(), progress)
+	verifyNilErr(t, err)
+	if len(events) != 7 {
+		t.Fatalf("expected 7 events during startup, got %v", events)
+	}
+	verifyStringsEqual(t, []string{"up one", "one"}, events[:2])
+	verifyIdenticalSets(t, []string{"up two", "two", "up three", "three"}, events[2:6])
+	verifyStringEquals(t, DoneService, events[6])
+
+	events = events[:0]
+	err = agent.Down(context.Background(), progress)
+	verifyNilErr(t, err)
+	if len(events) != 7 {
+		t.Fatalf("expected 7 events during shutdown, got %v", events)
+	}
+	verifyIdenticalSets(t, []string{"down two", "two", "down three", "three"}, events[:4])
+	verifyStringsEqual(t, []string{"down one", "one", DoneService}, events[4:])
+}
+
+func TestAgentServiceCount(t *testing.T) {
+	mgr := New("A Boot Sequence")
+	mgr.Register("one", NoOp, NoOp)
+
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+	verifyCountEq(t, 1, uint32(agent.ServiceCount()))
+
+	mgr.Register("two", NoOp, NoOp)
+	mgr.Register("three", NoOp, NoOp).After("two")
+
+	agent, err = mgr.Agent()
+	verifyNilErr(t, err)
+	verifyCountEq(t, 3, uint32(agent.ServiceCount()))
+
+	mgr.Register("four", NoOp, NoOp).After("three")
+	mgr.Register("five", NoOp, NoOp).After("three")
+
+	agent, err = mgr.Agent()
+	verifyNilErr(t, err)
+	verifyCountEq(t, 5, uint32(agent.ServiceCount()))
+}
+
+func TestAgentUp(t *testing.T) {
+	t.Run("it runs all services", func(t *testing.T) {
+		mgr := New("Three-service boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("three", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(4)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one", "two", "three", DoneService}, updater.actual)
+	})
+
+	t.Run("it runs dependent services in chronological order", func(t *testing.T) {
+		mgr := New("Three-service boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		mgr.Register("three", NoOp, NoOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(4)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		orderPreserved := verifyStringsEqual(t, []string{"one", "two", "three", DoneService}, updater.actual)
+		verifyOrderPreserved(t, orderPreserved)
+	})
+
+	t.Run("it runs services in chronological order (advanced case)", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, ErrOp).After("one")
+		mgr.Register("three", NoOp, NoOp).After("two")
+		mgr.Register("four", NoOp, NoOp).After("three")
+		mgr.Register("five", ErrOp, NoOp).After("four")  // Fails on fifth "up" Service.
+		mgr.Register("six", PanicOp, NoOp).After("five") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(7)
+
+		err = agent.Up(context.Background(), updater.progress())
+		verifyErrorType(t, err, errService)
+		orderPreserved := verifyStringsEqual(t, []string{"one", "two", "three", "four", "five"}, updater.actual)
+		verifyOrderPreserved(t, orderPreserved)
+	})
+
+	t.Run("it fails if called while booting up", func(t *testing.T) {
+		mgr := New("Three-service boot sequence")
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp).After("one")
+		mgr.Register("three", SleepOp, NoOp).After("two")
+		mgr.Register("four", SleepOp, NoOp).After("three")
+		mgr.Register("five", SleepOp, NoOp).After("four")
+		mgr.Register("six", SleepOp, NoOp).After("five")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var (
+			err1, err2 error
+			wg         sync.WaitGroup
+		)
+
+		wg.Add(2)
+		go func() {
+			err1 = agent.Up(context.Background(), nil)
+			wg.Done()
+		}()
+
+		go func() {
+			err2 = agent.Up(context.Background(), nil)
+			wg.Done()
+		}()
+
+		wg.Wait()
+		if err1 == nil && err2 == nil {
+			t.Error("expected err1 or err2 to be non-nil")
+		}
+	})
+}
+
+func TestAgentUpStaged(t *testing.T) {
+	t.Run("it promotes staged services once their group is up", func(t *testing.T) {
+		var (
+			lock   sync.Mutex
+			events []string
+		)
+		record := func(event string) Func {
+			return func() error {
+				lock.Lock()
+				events = append(events, event)
+				lock.Unlock()
+				return nil
+			}
+		}
+
+		mgr := New("Staged boot sequence")
+		mgr.RegisterStaged("one", record("one up"), record("one promote"), NoOp)
+		mgr.Register("two", record("two up"), NoOp)
+		mgr.Register("three", record("three up"), NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(4)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"two", "one", "three", DoneService}, updater.actual)
+
+		if len(events) != 4 {
+			t.Fatalf("expected 4 events, got %v", events)
+		}
+		verifyIdenticalSets(t, []string{"one up", "two up"}, events[:2])
+		verifyStringEquals(t, "one promote", events[2])
+		verifyStringEquals(t, "three up", events[3])
+	})
+
+	t.Run("it stops if a promote function fails", func(t *testing.T) {
+		mgr := New("Staged boot sequence")
+		mgr.RegisterStaged("one", NoOp, ErrOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(2)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyErrorType(t, err, errService)
+		verifyStringsEqual(t, []string{"one"}, updater.actual)
+	})
+
+	t.Run("it does not promote during shutdown", func(t *testing.T) {
+		var promoted uint32
+		promote := func() error {
+			promoted++
+			return nil
+		}
+
+		mgr := New("Staged boot sequence")
+		mgr.RegisterStaged("one", NoOp, promote, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+		verifyCountEq(t, promoted, 1)
+	})
+}
+
+func TestAgentUpWithControl(t *testing.T) {
+	t.Run("it halts the sequence when fn returns an error", func(t *testing.T) {
+		errHalt := errors.New("halted by operator")
+		mgr := New("Controlled boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var reports []Progress
+		err = agent.UpWithControl(context.Background(), func(p Progress) error {
+			reports = append(reports, p)
+			return errHalt
+		})
+		verifyErrorType(t, err, errHalt)
+		if len(reports) != 2 {
+			t.Fatalf("expected 2 reports, got %v", reports)
+		}
+		verifyStringEquals(t, "one", reports[0].Service)
+		verifyStringEquals(t, DoneService, reports[1].Service)
+		verifyErrorType(t, reports[1].Err, errHalt)
+	})
+
+	t.Run("it runs the whole sequence when fn returns nil", func(t *testing.T) {
+		mgr := New("Controlled boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(3)
+		err = agent.UpWithControl(context.Background(), func(p Progress) error {
+			updater.progress()(p)
+			return nil
+		})
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one", "two", DoneService}, updater.actual)
+	})
+}
+
+func TestAgentUpReadyOn(t *testing.T) {
+	t.Run("it returns once the ready service is up", func(t *testing.T) {
+		release := make(chan struct{})
+		wait := func() error {
+			<-release
+			return nil
+		}
+
+		mgr := New("Ready boot sequence")
+		mgr.Register("auth", NoOp, NoOp)
+		mgr.Register("cache", wait, NoOp).After("auth")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		result, err := agent.UpReadyOn(context.Background(), "auth", nil)
+		verifyNilErr(t, err)
+		select {
+		case err = <-result:
+			t.Fatalf("expected the sequence to be in progress, got %v", err)
+		default:
+		}
+
+		close(release)
+		verifyNilErr(t, <-result)
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+	})
+
+	t.Run("it returns an error for an unknown service", func(t *testing.T) {
+		mgr := New("Ready boot sequence")
+		mgr.Register("auth", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		_, err = agent.UpReadyOn(context.Background(), "nobody", nil)
+		verifyErrorType(t, err, UnregisteredServiceError("nobody"))
+	})
+
+	t.Run("it returns an error if the sequence stops before the service is up", func(t *testing.T) {
+		mgr := New("Ready boot sequence")
+		mgr.Register("auth", ErrOp, NoOp)
+		mgr.Register("api", PanicOp, NoOp).After("auth") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		result, err := agent.UpReadyOn(context.Background(), "api", nil)
+		verifyErrorType(t, err, errService)
+		verifyErrorType(t, <-result, errService)
+	})
+}
+
+func TestAgentUpPrefix(t *testing.T) {
+	t.Run("it runs the matching services and their predecessors", func(t *testing.T) {
+		mgr := New("Partial boot sequence")
+		mgr.Register("config", NoOp, NoOp)
+		mgr.Register("db.primary", NoOp, NoOp).After("config")
+		mgr.Register("db.replica", NoOp, NoOp).After("db.primary")
+		mgr.Register("cache.redis", PanicOp, PanicOp).After("config") // PanicOp should never execute.
+		verifyStringsEqual(t, []string{"db.primary", "db.replica"}, mgr.ServiceNamesWithPrefix("db."))
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(4)
+		err = agent.UpPrefix(context.Background(), "db.", updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"config", "db.primary", "db.replica", DoneService}, updater.actual)
+
+		updater = newIndexUpdater(4)
+		err = agent.Down(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"db.replica", "db.primary", "config", DoneService}, updater.actual)
+	})
+
+	t.Run("it returns an error if no services match", func(t *testing.T) {
+		mgr := New("Partial boot sequence")
+		mgr.Register("config", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.UpPrefix(context.Background(), "db.", nil)
+		verifyErrorType(t, err, UnregisteredServiceError("db."))
+	})
+
+	t.Run("it starts a single selection when called concurrently", func(t *testing.T) {
+		mgr := New("Partial boot sequence")
+		mgr.Register("db.primary", NoOp, NoOp)
+		mgr.Register("cache.redis", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		errs := make(chan error, 2)
+		for _, prefix := range []string{"db.", "cache."} {
+			go func(prefix string) {
+				errs <- agent.UpPrefix(context.Background(), prefix, nil)
+			}(prefix)
+		}
+		first, second := <-errs, <-errs
+		if first != nil {
+			first, second = second, first
+		}
+		verifyNilErr(t, first)
+		verifyErrorType(t, second, InvalidStateError(inProgressErrorMessage))
+		verifyCountEq(t, 1, uint32(agent.ServiceCount()))
+	})
+}
+
+func TestAgentUpStress(t *testing.T) {
+	t.Run("it runs the sequence repeatedly", func(t *testing.T) {
+		var ups, downs int32
+		mgr := New("Stressed boot sequence")
+		mgr.Register("one", func() error {
+			atomic.AddInt32(&ups, 1)
+			return nil
+		}, func() error {
+			atomic.AddInt32(&downs, 1)
+			return nil
+		})
+		mgr.Register("two", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.UpStress(context.Background(), 10)
+		verifyNilErr(t, err)
+		verifyCountEq(t, uint32(ups), 10)
+		verifyCountEq(t, uint32(downs), 10)
+
+		err = agent.Up(context.Background(), nil) // The Agent itself is still idle.
+		verifyNilErr(t, err)
+	})
+
+	t.Run("it aggregates errors", func(t *testing.T) {
+		mgr := New("Stressed boot sequence")
+		mgr.Register("one", ErrOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.UpStress(context.Background(), 3)
+		if !errors.Is(err, errService) {
+			t.Fatalf("expected error to wrap %v, got %v", errService, err)
+		}
+		verifyStringEquals(t, "iteration 1: service has failed\niteration 2: service has failed\niteration 3: service has failed", err.Error())
+	})
+}
+
+func TestAgentUpWarmup(t *testing.T) {
+	t.Run("it runs warmup functions before any up function", func(t *testing.T) {
+		var (
+			lock   sync.Mutex
+			events []string
+		)
+		record := func(event string) Func {
+			return func() error {
+				lock.Lock()
+				events = append(events, event)
+				lock.Unlock()
+				return nil
+			}
+		}
+
+		mgr := New("Warm boot sequence")
+		mgr.Register("one", record("one up"), record("one down")).WithWarmup(record("one warmup"))
+		mgr.Register("two", record("two up"), record("two down")).WithWarmup(record("two warmup")).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(3)
+		err = agent.Up(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one", "two", DoneService}, updater.actual)
+
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one warmup", "two warmup", "one up", "two up", "two down", "one down"}, events)
+	})
+
+	t.Run("it aborts startup if a warmup function fails", func(t *testing.T) {
+		mgr := New("Warm boot sequence")
+		mgr.Register("one", PanicOp, NoOp).WithWarmup(ErrOp) // PanicOp should never execute.
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var reports []Progress
+		err = agent.Up(context.Background(), func(p Progress) { reports = append(reports, p) })
+		verifyErrorType(t, err, errService)
+		if len(reports) != 1 {
+			t.Fatalf("expected 1 report, got %v", reports)
+		}
+		verifyStringEquals(t, "one", reports[0].Service)
+		verifyStringEquals(t, WarmupError.String(), reports[0].Kind.String())
+	})
+
+	t.Run("it reports the end of the sequence if warmup is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mgr := New("Warm boot sequence")
+		mgr.Register("one", PanicOp, NoOp).WithWarmup(func() error { cancel(); return nil })
+		mgr.Register("two", PanicOp, NoOp).WithWarmup(PanicOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var reports []Progress
+		err = agent.Up(ctx, func(p Progress) { reports = append(reports, p) })
+		verifyErrorType(t, err, context.Canceled)
+		if len(reports) != 1 {
+			t.Fatalf("expected 1 report, got %v", reports)
+		}
+		verifyStringEquals(t, DoneService, reports[0].Service)
+		verifyStringEquals(t, WarmupError.String(), reports[0].Kind.String())
+	})
+
+	t.Run("it reports the end of the sequence if a warmup group is interrupted", func(t *testing.T) {
+		mgr := New("Warm boot sequence").WithGroupRunner(&failingGroupRunner{err: errService})
+		mgr.Register("one", PanicOp, NoOp).WithWarmup(PanicOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var reports []Progress
+		err = agent.Up(context.Background(), func(p Progress) { reports = append(reports, p) })
+		verifyErrorType(t, err, errService)
+		if len(reports) != 1 {
+			t.Fatalf("expected 1 report, got %v", reports)
+		}
+		verifyStringEquals(t, DoneService, reports[0].Service)
+		verifyStringEquals(t, WarmupError.String(), reports[0].Kind.String())
+	})
+}
+
+func TestAgentDown(t *testing.T) {
+	t.Run("it runs all services", func(t *testing.T) {
+		mgr := New("Three-service boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("three", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater1 := newIndexUpdater(4)
+		err = agent.Up(context.Background(), updater1.progress())
+		verifyNilErr(t, err)
+
+		updater2 := newIndexUpdater(4)
+		err = agent.Down(context.Background(), updater2.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one", "two", "three", DoneService}, updater2.actual)
+	})
+
+	t.Run("it runs services in reverse chronological order", func(t *testing.T) {
+		mgr := New("Three-service boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		mgr.Register("three", NoOp, NoOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater1 := newIndexUpdater(4)
+		err = agent.Up(context.Background(), updater1.progress())
+		verifyNilErr(t, err)
+
+		updater2 := newIndexUpdater(4)
+		err = agent.Down(context.Background(), updater2.progress())
+		verifyNilErr(t, err)
+		orderPreserved := verifyStringsEqual(t, []string{"three", "two", "one", DoneService}, updater2.actual)
+		verifyOrderPreserved(t, orderPreserved)
+	})
+
+	t.Run("it runs services in reverse order (advanced case)", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, PanicOp)            // PanicOp should never execute.
+		mgr.Register("two", NoOp, ErrOp).After("one") // Fails on fifth Down service.
+		mgr.Register("three", NoOp, NoOp).After("two")
+		mgr.Register("four", NoOp, NoOp).After("three")
+		mgr.Register("five", NoOp, NoOp).After("four")
+		mgr.Register("six", NoOp, NoOp).After("five")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		updater1 := newIndexUpdater(7)
+		err = agent.Up(context.Background(), updater1.progress())
+		verifyNilErr(t, err)
+
+		updater2 := newIndexUpdater(7)
+		err = agent.Down(context.Background(), updater2.progress())
+		verifyErrorType(t, err, errService)
+		orderPreserved := verifyStringsEqual(t, []string{"six", "five", "four", "three", "two"}, updater2.actual)
+		verifyOrderPreserved(t, orderPreserved)
+	})
+
+	t.Run("it fails if called while booting up", func(t *testing.T) {
+		mgr := New("Three-service boot sequence")
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp)
+		mgr.Register("three", SleepOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var (
+			err1, err2 error
+			wg         sync.WaitGroup
+		)
+
+		wg.Add(2)
+		func() {
+			err1 = agent.Up(context.Background(), nil)
+			wg.Done()
+		}()
+
+		func() {
+			err2 = agent.Up(context.Background(), nil)
+			wg.Done()
+		}()
+
+		wg.Wait()
+		if err1 == nil && err2 == nil {
+			t.Error("expected err1 or err2 to be non-nil")
+		}
+	})
+}
+
+func TestManagerRegisterFinalizer(t *testing.T) {
+	errFinalizer := errors.New("finalizer failure")
+	var calls []string
+	call := func(name string, err error) Func {
+		return func() error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	t.Run("it runs finalizers after the shutdown sequence", func(t *testing.T) {
+		calls = nil
+		mgr := New("Finalized boot sequence")
+		mgr.Register("one", call("one up", nil), call("one down", nil))
+		mgr.RegisterFinalizer("first", call("first", nil))
+		mgr.RegisterFinalizer("second", call("second", nil))
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyStringsEqual(t, []string{"one up"}, calls)
+
+		var reports []string
+		err = agent.Down(context.Background(), func(p Progress) {
+			reports = append(reports, p.Service+":"+strconv.Itoa(p.Remaining))
+		})
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one up", "one down", "first", "second"}, calls)
+		verifyStringsEqual(t, []string{"one:2", "first:1", "second:0", DoneService + ":0"}, reports)
+	})
+
+	t.Run("it runs every finalizer and returns their errors", func(t *testing.T) {
+		calls = nil
+		mgr := New("Finalized boot sequence")
+		mgr.Register("one", NoOp, ErrOp)
+		mgr.RegisterFinalizer("first", call("first", errFinalizer))
+		mgr.RegisterFinalizer("second", call("second", nil))
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		err = agent.Down(context.Background(), nil)
+		verifyStringsEqual(t, []string{"first", "second"}, calls)
+		if !errors.Is(err, errService) || !errors.Is(err, errFinalizer) {
+			t.Fatalf("expected both the service and finalizer errors, got %v", err)
+		}
+		verifyErrorType(t, agent.LastRunFailures()["first"], errFinalizer)
+	})
+
+	t.Run("it rejects finalizers on a frozen manager", func(t *testing.T) {
+		mgr := New("Finalized boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		frozen := mgr.Freeze()
+		frozen.RegisterFinalizer("first", PanicOp)
+		_, err := frozen.Agent()
+		verifyErrorType(t, err, SealedManagerError("first"))
+	})
+}
+
+func TestManagerWithBestEffortDown(t *testing.T) {
+	t.Run("it shuts down the remaining services after a failure", func(t *testing.T) {
+		mgr := New("Boot it!").WithBestEffortDown(true)
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, ErrOp).After("one")
+		mgr.Register("three", NoOp, ErrOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+
+		updater := newIndexUpdater(4)
+		err = agent.Down(context.Background(), updater.progress())
+		if !errors.Is(err, errService) {
+			t.Fatalf("expected joined errors to contain %v, got %v", errService, err)
+		}
+		verifyStringsEqual(t, []string{"three", "two", "one", DoneService}, updater.actual)
+		if len(agent.LastRunFailures()) != 2 {
+			t.Fatalf("expected 2 failures, got %v", agent.LastRunFailures())
+		}
+	})
+
+	t.Run("it returns the errors of all failed services", func(t *testing.T) {
+		errOther := errors.New("other service has failed")
+		mgr := New("Boot it!").WithBestEffortDown(true)
+		mgr.Register("one", NoOp, func() error { return errOther })
+		mgr.Register("two", NoOp, ErrOp).After("one")
+		mgr.Register("three", NoOp, ErrOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		err = agent.Down(context.Background(), nil)
+		var merr MultiError
+		if !errors.As(err, &merr) {
+			t.Fatalf("expected MultiError, got %v", err)
+		}
+		verifyStringEquals(t, "1. three: service has failed\n2. two: service has failed\n3. one: other service has failed",
+			err.Error())
+		if !errors.Is(err, errService) || !errors.Is(err, errOther) {
+			t.Fatalf("expected MultiError to contain both errors, got %v", err)
+		}
+		var serr ServiceError
+		if !errors.As(merr[2], &serr) || serr.Service != "one" {
+			t.Fatalf("expected ServiceError for one, got %v", merr[2])
+		}
+		if !errors.As(err, &serr) || serr.Service != "three" {
+			t.Fatalf("expected the first ServiceError to be for three, got %v", serr)
+		}
+	})
+
+	t.Run("it returns the error of a group runner that fails without failed services", func(t *testing.T) {
+		errRunner := errors.New("group runner has failed")
+		runner := &failingGroupRunner{}
+		mgr := New("Boot it!").WithBestEffortDown(true).WithGroupRunner(runner)
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		runner.err = errRunner
+		err = agent.Down(context.Background(), nil)
+		verifyErrorType(t, err, errRunner)
+	})
+
+	t.Run("it leaves the startup sequence unaffected", func(t *testing.T) {
+		mgr := New("Boot it!").WithBestEffortDown(true)
+		mgr.Register("one", ErrOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+	})
+}
+
+func TestAgentLastRunFailures(t *testing.T) {
+	errOther := errors.New("other service has failed")
+	mgr := New("Failing boot sequence")
+	mgr.Register("one", NoOp, ErrOp)
+	mgr.Register("two", ErrOp, NoOp)
+	mgr.Register("three", func() error { return errOther }, NoOp)
+	mgr.Register("four", PanicOp, NoOp).After("two") // PanicOp should never execute.
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+	if len(agent.LastRunFailures()) != 0 {
+		t.Fatalf("expected no failures before the first run, got %v", agent.LastRunFailures())
+	}
+
+	err = agent.Up(context.Background(), nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	failures := agent.LastRunFailures()
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", failures)
+	}
+	verifyErrorType(t, failures["two"], errService)
+	verifyErrorType(t, failures["three"], errOther)
+}
+
+func TestAgentDownOnce(t *testing.T) {
+	t.Run("it shuts down once", func(t *testing.T) {
+		var downs int32
+		mgr := New("Idempotent boot sequence")
+		mgr.Register("one", NoOp, func() error {
+			atomic.AddInt32(&downs, 1)
+			return nil
+		})
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 3)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = agent.DownOnce(context.Background(), nil)
+			}(i)
+		}
+		wg.Wait()
+		for _, err = range errs {
+			verifyNilErr(t, err)
+		}
+		verifyCountEq(t, uint32(downs), 1)
+	})
+
+	t.Run("it returns the first error to all callers", func(t *testing.T) {
+		mgr := New("Idempotent boot sequence")
+		mgr.Register("one", NoOp, ErrOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		verifyErrorType(t, agent.DownOnce(context.Background(), nil), errService)
+		verifyErrorType(t, agent.DownOnce(context.Background(), nil), errService)
+	})
+
+	t.Run("it shuts down once the startup sequence has completed", func(t *testing.T) {
+		mgr := New("Idempotent boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyErrorType(t, agent.DownOnce(context.Background(), nil), InvalidStateError(idleErrorMessage))
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyNilErr(t, agent.DownOnce(context.Background(), nil))
+		verifyNilErr(t, agent.DownOnce(context.Background(), nil))
+	})
+}
+
+func TestAgentDownForward(t *testing.T) {
+	mgr := New("Lease boot sequence")
+	mgr.Register("one", NoOp, NoOp)
+	mgr.Register("two", NoOp, NoOp).After("one")
+	mgr.Register("three", NoOp, NoOp).After("two")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	err = agent.Up(context.Background(), nil)
+	verifyNilErr(t, err)
+
+	var names []string
+	err = agent.DownForward(context.Background(), func(p Progress) { names = append(names, p.Service) })
+	verifyNilErr(t, err)
+	verifyStringEquals(t, "one,two,three,"+DoneService, strings.Join(names, ","))
+
+	err = agent.Down(context.Background(), nil)
+	verifyErrorType(t, err, InvalidStateError(inProgressErrorMessage))
+}
+
+func TestAgentDownStarted(t *testing.T) {
+	t.Run("it only shuts down services that were brought up", func(t *testing.T) {
+		mgr := New("Partial boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", ErrOp, PanicOp).After("one") // PanicOp should never execute.
+		mgr.Register("three", PanicOp, PanicOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		if !agent.CanDown() {
+			t.Fatal("expected the agent to allow the shutdown sequence")
+		}
+
+		updater := newIndexUpdater(2)
+		err = agent.Down(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one", DoneService}, updater.actual)
+	})
+
+	t.Run("it only shuts down services that came up before cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mgr := New("Partial boot sequence")
+		mgr.Register("one", func() error { cancel(); return nil }, NoOp)
+		mgr.Register("two", PanicOp, PanicOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(ctx, nil)
+		verifyErrorType(t, err, context.Canceled)
+
+		updater := newIndexUpdater(2)
+		err = agent.Down(context.Background(), updater.progress())
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"one", DoneService}, updater.actual)
+	})
+}
+
+func TestProgressKind(t *testing.T) {
+	mgr := New("Failing boot sequence")
+	mgr.Register("one", NoOp, ErrOp)
+	mgr.Register("two", ErrOp, NoOp).After("one")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	var kinds []Kind
+	record := func(p Progress) { kinds = append(kinds, p.Kind) }
+
+	err = agent.Up(context.Background(), record)
+	verifyErrorType(t, err, errService)
+	if len(kinds) != 2 || kinds[0] != NoError || kinds[1] != UpError {
+		t.Fatalf("expected kinds %v, got %v", []Kind{NoError, UpError}, kinds)
+	}
+
+	mgr.Register("two", NoOp, NoOp).After("one")
+	agent, err = mgr.Agent()
+	verifyNilErr(t, err)
+	err = agent.Up(context.Background(), nil)
+	verifyNilErr(t, err)
+
+	kinds = kinds[:0]
+	err = agent.Down(context.Background(), record)
+	verifyErrorType(t, err, errService)
+	if len(kinds) != 2 || kinds[0] != NoError || kinds[1] != DownError {
+		t.Fatalf("expected kinds %v, got %v", []Kind{NoError, DownError}, kinds)
+	}
+	verifyStringEquals(t, "down error", kinds[1].String())
+}
+
+func TestTee(t *testing.T) {
+	mgr := New("Boot it!")
+	mgr.Register("one", NoOp, NoOp)
+	mgr.Register("two", NoOp, NoOp).After("one")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	var calls []string
+	logger := func(p Progress) { calls = append(calls, "log:"+p.Service) }
+	metrics := func(p Progress) { calls = append(calls, "metric:"+p.Service) }
+	err = agent.Up(context.Background(), Tee(logger, nil, metrics))
+	verifyNilErr(t, err)
+
+	expected := []string{
+		"log:one", "metric:one",
+		"log:two", "metric:two",
+		"log:" + DoneService, "metric:" + DoneService,
+	}
+	verifyStringsEqual(t, expected, calls)
+}
+
+func TestProgressRemaining(t *testing.T) {
+	t.Run("it counts down the services of each phase", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		mgr.Register("three", NoOp, NoOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var remaining []string
+		progressFn := func(p Progress) {
+			remaining = append(remaining, p.Service+":"+strconv.Itoa(p.Remaining))
+		}
+		verifyNilErr(t, agent.Up(context.Background(), progressFn))
+		verifyStringsEqual(t, []string{"one:2", "two:1", "three:0", DoneService + ":0"}, remaining)
+
+		remaining = nil
+		verifyNilErr(t, agent.Down(context.Background(), progressFn))
+		verifyStringsEqual(t, []string{"three:2", "two:1", "one:0", DoneService + ":0"}, remaining)
+	})
+
+	t.Run("it counts concurrent services once each", func(t *testing.T) {
+		mgr := New("Boot it!")
+		for i := 0; i < 10; i++ {
+			mgr.Register("service"+strconv.Itoa(i), NoOp, NoOp)
+		}
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var lock sync.Mutex
+		seen := make(map[int]bool)
+		err = agent.Up(context.Background(), func(p Progress) {
+			if p.Service == DoneService {
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			seen[p.Remaining] = true
+		})
+		verifyNilErr(t, err)
+		for i := 0; i < 10; i++ {
+			if !seen[i] {
+				t.Fatalf("expected a report with %d remaining services, got %v", i, seen)
+			}
+		}
+	})
+
+	t.Run("it leaves services that didn't run", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", ErrOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var last Progress
+		_ = agent.Up(context.Background(), func(p Progress) { last = p })
+		verifyStringEquals(t, "one", last.Service)
+		verifyCountEq(t, uint32(last.Remaining), 1)
+	})
+}
+
+func TestProgressMeta(t *testing.T) {
+	kv := map[string]string{"owner": "team-db"}
+	mgr := New("Labelled boot sequence")
+	mgr.Register("db", NoOp, NoOp).WithMeta(kv)
+	mgr.Register("api", NoOp, NoOp).After("db")
+	kv["owner"] = "changed" // Should not affect the Service.
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	var reports []Progress
+	err = agent.Up(context.Background(), func(p Progress) { reports = append(reports, p) })
+	verifyNilErr(t, err)
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 reports, got %v", reports)
+	}
+	verifyStringEquals(t, "team-db", reports[0].Meta["owner"])
+	if reports[1].Meta != nil || reports[2].Meta != nil {
+		t.Fatalf("expected nil Meta, got %v and %v", reports[1].Meta, reports[2].Meta)
+	}
+}
+
+func TestProgressSequence(t *testing.T) {
+	var lock sync.Mutex
+	seen := make(map[string][]string)
+	observe := func(p Progress) {
+		lock.Lock()
+		seen[p.Sequence] = append(seen[p.Sequence], p.Service)
+		lock.Unlock()
+	}
+
+	for _, name := range []string{"data layer", "api layer"} {
+		mgr := New(name)
+		mgr.Register(strings.Fields(name)[0], NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyNilErr(t, agent.Up(context.Background(), observe))
+	}
+	verifyStringsEqual(t, []string{"data", DoneService}, seen["data layer"])
+	verifyStringsEqual(t, []string{"api", DoneService}, seen["api layer"])
+}
+
+func TestAgentCancel(t *testing.T) {
+	t.Run("it stops before executing all services", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp).After("one")
+		mgr.Register("three", SleepOp, NoOp).After("two")
+		mgr.Register("four", SleepOp, NoOp).After("three")
+		mgr.Register("five", SleepOp, NoOp).After("four")
+		mgr.Register("six", PanicOp, NoOp).After("five")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		updater := newIndexUpdater(7)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			err = agent.Up(ctx, updater.progress())
+			verifyErrorType(t, err, context.Canceled)
+			wg.Done()
+		}()
+		cancel()
+
+		wg.Wait()
+		for _, a := range updater.actual {
+			if a == "five" {
+				// Execution should stop long before reaching the fifth service.
+				t.Error("did not expect to encounter service five due to cancellation")
+			}
+		}
+	})
+}
+
+func TestAgentCancelCause(t *testing.T) {
+	t.Run("it returns the cause of the cancellation", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp).After("one")
+		mgr.Register("three", PanicOp, NoOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		cause := errors.New("operator aborted the boot")
+		ctx, cancel := context.WithCancelCause(context.Background())
+		updater := newIndexUpdater(4)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			err = agent.Up(ctx, updater.progress())
+			wg.Done()
+		}()
+		cancel(cause)
+
+		wg.Wait()
+		verifyErrorType(t, err, cause)
+	})
+}
+
+func TestAgentTimeout(t *testing.T) {
+	t.Run("it stops before executing all services", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp).After("one")
+		mgr.Register("three", SleepOp, NoOp).After("two")
+		mgr.Register("four", SleepOp, NoOp).After("three")
+		mgr.Register("five", SleepOp, NoOp).After("four")
+		mgr.Register("six", PanicOp, NoOp).After("five")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		updater := newIndexUpdater(7)
+		err = agent.Up(ctx, updater.progress())
+		verifyErrorType(t, err, context.DeadlineExceeded)
+
+		for _, a := range updater.actual {
+			if a == "five" {
+				// Execution should stop long before reaching the fifth service.
+				t.Fatal("did not expect to encounter service five due to cancellation")
+			}
+		}
+	})
+}
+
+func TestManagerWithCancelGrace(t *testing.T) {
+	t.Run("it abandons services that outlast the grace period", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		started := make(chan struct{})
+
+		mgr := New("Graceful boot sequence").WithCancelGrace(20 * time.Millisecond)
+		mgr.Register("fast", NoOp, NoOp)
+		mgr.Register("slow", func() error {
+			close(started)
+			<-release
+			return nil
+		}, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+		err = agent.Up(ctx, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		var abandoned AbandonedServicesError
+		if !errors.As(err, &abandoned) {
+			t.Fatalf("expected AbandonedServicesError, got %v", err)
+		}
+		verifyStringEquals(t, "abandoned services: slow", abandoned.Error())
+	})
+
+	t.Run("it waits for services that finish within the grace period", func(t *testing.T) {
+		mgr := New("Graceful boot sequence").WithCancelGrace(time.Second)
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = agent.Up(ctx, nil)
+		verifyErrorType(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestAgentReportAbandonedServices(t *testing.T) {
+	// The startup sequence leaves "slow" running, which then completes, and reports, while the shutdown sequence runs.
+	// As the startup sequence has ended by then, "slow" isn't brought up, and only the finalizer runs during the shutdown
+	// sequence.
+	runner := &detachedGroupRunner{}
+	runner.detach.Store(true)
+	release, slowReported := make(chan struct{}), make(chan struct{})
+	mgr := New("Boot it!").WithGroupRunner(runner)
+	mgr.RegisterFinalizer("cleanup", func() error {
+		close(release)
+		select {
+		case <-slowReported:
+		case <-time.After(time.Second): // The report went elsewhere.
+		}
+		return nil
+	})
+	mgr.Register("slow", func() error {
+		<-release
+		return nil
+	}, NoOp)
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	var lock sync.Mutex
+	var up, down []string
+	record := func(names *[]string, p Progress) {
+		lock.Lock()
+		defer lock.Unlock()
+		*names = append(*names, p.Service)
+	}
+
+	err = agent.Up(context.Background(), func(p Progress) {
+		record(&up, p)
+		if p.Service == "slow" {
+			close(slowReported)
+		}
+	})
+	verifyNilErr(t, err)
+
+	runner.detach.Store(false)
+	err = agent.Down(context.Background(), func(p Progress) { record(&down, p) })
+	verifyNilErr(t, err)
+
+	lock.Lock()
+	defer lock.Unlock()
+	verifyStringEquals(t, "cleanup,"+DoneService, strings.Join(down, ","))
+	sort.Strings(up)
+	verifyStringEquals(t, strings.Join([]string{DoneService, "slow"}, ","), strings.Join(up, ","))
+}
+
+func TestAgentAbandonedServicesLeaveNextRun(t *testing.T) {
+	// As in TestAgentReportAbandonedServices, "slow" completes while the shutdown sequence runs, this time with an error.
+	runner := &detachedGroupRunner{}
+	runner.detach.Store(true)
+	release, slowReported := make(chan struct{}), make(chan struct{})
+	mgr := New("Boot it!").WithGroupRunner(runner)
+	mgr.RegisterFinalizer("cleanup", func() error {
+		close(release)
+		select {
+		case <-slowReported:
+		case <-time.After(time.Second):
+		}
+		return nil
+	})
+	mgr.Register("slow", func() error {
+		<-release
+		return errService
+	}, NoOp)
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	err = agent.Up(context.Background(), func(p Progress) {
+		if p.Service == "slow" {
+			close(slowReported)
+		}
+	})
+	verifyNilErr(t, err)
+
+	runner.detach.Store(false)
+	err = agent.Down(context.Background(), nil)
+	verifyNilErr(t, err)
+
+	if failures := agent.LastRunFailures(); len(failures) != 0 {
+		t.Fatalf("expected no failures for the shutdown sequence, got %v", failures)
+	}
+	for _, timing := range agent.Timeline() {
+		if timing.Service == "slow" {
+			t.Fatalf("expected no timing for the abandoned service, got %v", timing)
+		}
+	}
+}
+
+func TestAgentUpWithLevelTimeout(t *testing.T) {
+	t.Run("it allots each priority group its own time", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp).After("one")
+		mgr.Register("three", SleepOp, NoOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		// The sequence takes longer than the time allotted to each group, but every group completes in time.
+		err = agent.UpWithLevelTimeout(context.Background(), 400*time.Millisecond, nil)
+		verifyNilErr(t, err)
+	})
+
+	t.Run("it attributes the timeout to the slow group", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", SleepOp, NoOp).After("one")
+		mgr.Register("three", PanicOp, NoOp).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.UpWithLevelTimeout(context.Background(), 50*time.Millisecond, nil)
+		verifyErrorType(t, err, LevelTimeoutError(2))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected error to wrap context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("it doesn't apply the timeout to the shutdown sequence", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, SleepOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.UpWithLevelTimeout(context.Background(), 50*time.Millisecond, nil)
+		verifyNilErr(t, err)
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+	})
+}
+
+func TestManagerWithStartupShutdownTimeout(t *testing.T) {
+	t.Run("it limits the startup sequence", func(t *testing.T) {
+		mgr := New("Boot it!").WithStartupTimeout(50 * time.Millisecond).WithShutdownTimeout(time.Minute)
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("it limits the shutdown sequence", func(t *testing.T) {
+		mgr := New("Boot it!").WithStartupTimeout(time.Minute).WithShutdownTimeout(50 * time.Millisecond)
+		mgr.Register("one", SleepOp, PanicOp) // PanicOp should never execute.
+		mgr.Register("two", NoOp, SleepOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		err = agent.Down(context.Background(), nil)
+		verifyErrorType(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("it keeps an earlier deadline of the context", func(t *testing.T) {
+		mgr := New("Boot it!").WithStartupTimeout(time.Minute)
+		mgr.Register("one", SleepOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = agent.Up(ctx, nil)
+		verifyErrorType(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestServiceWithTimeout(t *testing.T) {
+	t.Run("it fails services that run out of time", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp).WithTimeout(20 * time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var reported error
+		err = agent.Up(context.Background(), func(p Progress) {
+			if p.Service == "one" {
+				reported = p.Err
+			}
+		})
+		verifyErrorType(t, err, ServiceTimeoutError("one"))
+		verifyErrorType(t, reported, ServiceTimeoutError("one"))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected error to wrap context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("it is bounded by the deadline of the context", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp).WithTimeout(time.Second)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err = agent.Up(ctx, nil)
+		verifyErrorType(t, err, context.DeadlineExceeded)
+		if elapsed := time.Since(start); elapsed >= 250*time.Millisecond {
+			t.Fatalf("expected the context deadline to stop the sequence early, took %s", elapsed)
+		}
+	})
+
+	t.Run("it succeeds within the timeout", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp).WithTimeout(time.Second)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+	})
+
+	t.Run("it passes its deadline to context-aware services", func(t *testing.T) {
+		stopped := make(chan error, 1)
+		mgr := New("Boot it!")
+		mgr.RegisterContext("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped <- context.Cause(ctx)
+			return ctx.Err()
+		}, func(context.Context) error { return nil }).WithTimeout(10 * time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, ServiceTimeoutError("slow"))
+		select {
+		case cause := <-stopped:
+			verifyErrorType(t, cause, ServiceTimeoutError("slow"))
+		case <-time.After(time.Second):
+			t.Fatal("expected the service to stop once its timeout expired")
+		}
+	})
+}
+
+func TestAgentOnComplete(t *testing.T) {
+	t.Run("it calls each function once per phase", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, ErrOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var ups, downs []error
+		agent.OnUpComplete(func(err error) { ups = append(ups, err) })
+		agent.OnDownComplete(func(err error) { downs = append(downs, err) })
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		if len(ups) != 1 || ups[0] != nil || len(downs) != 0 {
+			t.Fatalf("expected a single successful startup, got %v and %v", ups, downs)
+		}
+
+		err = agent.Down(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		if len(ups) != 1 || len(downs) != 1 || downs[0] != errService {
+			t.Fatalf("expected a single failed shutdown, got %v and %v", ups, downs)
+		}
+	})
+
+	t.Run("it reports failed warmups", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", PanicOp, NoOp).WithWarmup(ErrOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var ups []error
+		agent.OnUpComplete(func(err error) { ups = append(ups, err) })
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		if len(ups) != 1 || ups[0] != errService {
+			t.Fatalf("expected a single failed startup, got %v", ups)
+		}
+	})
+}
+
+func TestAgentInjectFailure(t *testing.T) {
+	var calls int32
+	count := func() error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+
+	t.Run("it fails the service instead of calling it", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		mgr := New("Boot it!")
+		mgr.Register("one", count, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		agent.InjectFailure("one", errService)
+		var reported error
+		err = agent.Up(context.Background(), func(p Progress) {
+			if p.Service == "one" {
+				reported = p.Err
+			}
+		})
+		verifyErrorType(t, err, errService)
+		verifyErrorType(t, reported, errService)
+		verifyCountEq(t, uint32(atomic.LoadInt32(&calls)), 0)
+	})
+
+	t.Run("it consumes the injection", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		mgr := New("Boot it!")
+		mgr.Register("one", count, ErrOp).WithRetry(1, time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		agent.InjectFailure("one", errService)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyCountEq(t, uint32(atomic.LoadInt32(&calls)), 1)
+	})
+
+	t.Run("it fails the shutdown sequence", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		agent.InjectFailure("one", errService)
+		err = agent.Down(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+	})
+
+	t.Run("it keeps persistent injections until cleared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		mgr := New("Boot it!")
+		mgr.Register("one", count, NoOp).WithRetry(2, time.Millisecond)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		agent.InjectPersistentFailure("one", errService)
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		verifyCountEq(t, uint32(atomic.LoadInt32(&calls)), 0)
+
+		agent, err = mgr.Agent()
+		verifyNilErr(t, err)
+		agent.InjectPersistentFailure("one", errService)
+		agent.ClearInjections()
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyCountEq(t, uint32(atomic.LoadInt32(&calls)), 1)
+	})
+}
+
+func TestAgentStopReason(t *testing.T) {
+	t.Run("it is NotStopped before running", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		if agent.StopReason() != NotStopped {
+			t.Fatalf("expected %s, got %s", NotStopped, agent.StopReason())
+		}
+	})
+
+	t.Run("it classifies the outcome of each run", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		expired, cancelExpired := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancelExpired()
+		<-expired.Done()
+
+		tests := []struct {
+			name     string
+			ctx      context.Context
+			up       Func
+			warmup   Func
+			expected StopReason
+		}{
+			{"complete", context.Background(), NoOp, nil, StoppedComplete},
+			{"service error", context.Background(), ErrOp, nil, StoppedServiceError},
+			{"warmup error", context.Background(), PanicOp, ErrOp, StoppedServiceError},
+			{"cancelled", cancelled, PanicOp, nil, StoppedCancelled},
+			{"timeout", expired, PanicOp, nil, StoppedTimeout},
+		}
+		for _, tt := range tests {
+			mgr := New("Boot it!")
+			srvc := mgr.Register("one", tt.up, NoOp)
+			if tt.warmup != nil {
+				srvc.WithWarmup(tt.warmup)
+			}
+			agent, err := mgr.Agent()
+			verifyNilErr(t, err)
+
+			_ = agent.Up(tt.ctx, nil)
+			if agent.StopReason() != tt.expected {
+				t.Fatalf("%s: expected %s, got %s", tt.name, tt.expected, agent.StopReason())
+			}
+		}
+	})
+
+	t.Run("it classifies level timeouts as timeouts", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", SleepOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		_ = agent.UpWithLevelTimeout(context.Background(), 10*time.Millisecond, nil)
+		if agent.StopReason() != StoppedTimeout {
+			t.Fatalf("expected %s, got %s", StoppedTimeout, agent.StopReason())
+		}
+	})
+
+	t.Run("it classifies halted sequences as cancelled", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		_ = agent.UpWithControl(context.Background(), func(Progress) error { return errService })
+		if agent.StopReason() != StoppedCancelled {
+			t.Fatalf("expected %s, got %s", StoppedCancelled, agent.StopReason())
+		}
+	})
+}
+
+func TestAgentVerifyReverse(t *testing.T) {
+	newAgent := func(t *testing.T) *Agent {
+		t.Helper()
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, NoOp).After("one")
+		mgr.Register("three", NoOp, NoOp).After("one")
+		mgr.Register("four", NoOp, NoOp).After("three")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		return agent
+	}
+
+	t.Run("it succeeds for a regular sequence", func(t *testing.T) {
+		agent := newAgent(t)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyNilErr(t, agent.Down(context.Background(), nil))
+		verifyNilErr(t, agent.VerifyReverse())
+	})
+
+	t.Run("it detects a shutdown sequence in the order of startup", func(t *testing.T) {
+		agent := newAgent(t)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyNilErr(t, agent.DownForward(context.Background(), nil))
+		var rerr ReverseOrderError
+		if !errors.As(agent.VerifyReverse(), &rerr) {
+			t.Fatalf("expected ReverseOrderError, got %v", agent.VerifyReverse())
+		}
+		verifyStringEquals(t, "shutdown is not the reverse of startup: group 1: expected (one), got (four)", rerr.Error())
+	})
+
+	t.Run("it detects a shutdown sequence that stopped early", func(t *testing.T) {
+		mgr := New("Boot it!")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", NoOp, ErrOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyErrorType(t, agent.Down(context.Background(), nil), errService)
+		verifyErrorType(t, agent.VerifyReverse(), ReverseOrderError("startup has 2 groups, shutdown has 1"))
+	})
+
+	t.Run("it requires a completed shutdown sequence", func(t *testing.T) {
+		agent := newAgent(t)
+		var serr InvalidStateError
+		if !errors.As(agent.VerifyReverse(), &serr) {
+			t.Fatalf("expected InvalidStateError, got %v", agent.VerifyReverse())
+		}
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		if !errors.As(agent.VerifyReverse(), &serr) {
+			t.Fatalf("expected InvalidStateError, got %v", agent.VerifyReverse())
+		}
+	})
+}
+
+func TestManagerRegisterStateful(t *testing.T) {
+	t.Run("it passes values from up to down", func(t *testing.T) {
+		type conn struct{ closed bool }
+		c := &conn{}
+
+		mgr := New("Stateful boot sequence")
+		mgr.RegisterStateful("db", func(st *State) error {
+			st.Set("db", c)
+			return nil
+		}, func(st *State) error {
+			v, ok := st.Get("db")
+			if !ok {
+				return errService
+			}
+			v.(*conn).closed = true
+			st.Delete("db")
+			return nil
+		})
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		v, ok := agent.State().Get("db")
+		if !ok || v != c {
+			t.Fatalf("expected state to contain %v, got %v", c, v)
+		}
+
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+		if !c.closed {
+			t.Fatal("expected the down function to close the connection")
+		}
+		if _, ok = agent.State().Get("db"); ok {
+			t.Fatal("expected the value to be deleted")
+		}
+	})
+
+	t.Run("it keeps a separate state per agent", func(t *testing.T) {
+		mgr := New("Stateful boot sequence")
+		mgr.RegisterStateful("one", func(st *State) error {
+			st.Set("one", true)
+			return nil
+		}, func(*State) error { return nil })
+		first, err := mgr.Agent()
+		verifyNilErr(t, err)
+		second, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = first.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		if _, ok := second.State().Get("one"); ok {
+			t.Fatal("expected the state of the second agent to be empty")
+		}
+	})
+
+	t.Run("it returns an error for nil funcs", func(t *testing.T) {
+		mgr := New("Stateful boot sequence")
+		mgr.RegisterStateful("one", nil, func(*State) error { return nil })
+		err := mgr.Validate()
+		verifyErrorType(t, err, NilFuncError("one"))
+	})
+}
+
+func TestAgentLevelCriticalPath(t *testing.T) {
+	sleep := func(d time.Duration) Func {
+		return func() error {
+			time.Sleep(d)
+			return nil
+		}
+	}
+
+	mgr := New("Timed boot sequence")
+	mgr.Register("fast", NoOp, NoOp)
+	mgr.Register("slow", sleep(20*time.Millisecond), NoOp)
+	mgr.Register("next", sleep(time.Millisecond), NoOp).After("slow")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	err = agent.Up(context.Background(), nil)
+	verifyNilErr(t, err)
+	levels := agent.LevelCriticalPath()
+	if len(levels) != 2 {
+		t.Fatalf("expected 2 levels, got %v", levels)
+	}
+	verifyStringEquals(t, "slow", levels[1].Service)
+	verifyStringEquals(t, "next", levels[2].Service)
+	if levels[1].Duration < 20*time.Millisecond {
+		t.Fatalf("expected a duration of at least 20ms, got %s", levels[1].Duration)
+	}
+}
+
+func TestAgentCriticalPath(t *testing.T) {
+	mgr := New("Timed boot sequence")
+	mgr.Register("config", NoOp, NoOp)
+	mgr.Register("db", NoOp, NoOp).After("config")
+	mgr.Register("cache", NoOp, NoOp).After("config")
+	mgr.Register("warm", NoOp, NoOp).After("cache")
+	mgr.Register("logs", NoOp, NoOp)
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	if agent.CriticalPath() != nil {
+		t.Fatalf("expected no critical path before running, got %v", agent.CriticalPath())
+	}
+
+	// The durations are injected rather than measured, so that the results don't depend on the speed of the machine.
+	t.Run("it follows the chain with the longest combined duration", func(t *testing.T) {
+		agent.timings = map[string]time.Duration{"config": 1, "db": 40, "cache": 10, "warm": 10, "logs": 30}
+		verifyStringEquals(t, "config,db", strings.Join(agent.CriticalPath(), ","))
+
+		agent.timings["warm"] = 50
+		verifyStringEquals(t, "config,cache,warm", strings.Join(agent.CriticalPath(), ","))
+	})
+
+	t.Run("it prefers the chain ending in the lowest priority on a tie", func(t *testing.T) {
+		agent.timings = map[string]time.Duration{"config": 1, "db": 20, "cache": 10, "warm": 10, "logs": 1}
+		verifyStringEquals(t, "config,db", strings.Join(agent.CriticalPath(), ","))
+	})
+
+	t.Run("it leaves out services that didn't run", func(t *testing.T) {
+		agent.timings = map[string]time.Duration{"config": 1, "cache": 10, "warm": 10, "logs": 1}
+		verifyStringEquals(t, "config,cache,warm", strings.Join(agent.CriticalPath(), ","))
+	})
+
+	t.Run("it measures the services of a run", func(t *testing.T) {
+		sleep := func() error {
+			time.Sleep(time.Millisecond)
+			return nil
+		}
+
+		mgr := New("Timed boot sequence")
+		mgr.Register("config", sleep, NoOp)
+		mgr.Register("db", sleep, NoOp).After("config")
+		mgr.Register("cache", sleep, NoOp).After("db")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "config,db,cache", strings.Join(agent.CriticalPath(), ","))
+	})
+}
+
+func TestServiceExclusive(t *testing.T) {
+	t.Run("it runs in a priority group of its own", func(t *testing.T) {
+		mgr := New("Exclusive boot sequence")
+		mgr.Register("db", NoOp, NoOp)
+		mgr.Register("migrate", NoOp, NoOp).Exclusive().After("db")
+		mgr.Register("cache", NoOp, NoOp).After("db")
+		mgr.Register("app", NoOp, NoOp).After("migrate")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(db) > (migrate) > (cache) > (app)", agent.String())
+		verifyStringsEqual(t, nil, agent.ConcurrentWith("migrate"))
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+	})
+
+	t.Run("it keeps the priorities of a subset contiguous", func(t *testing.T) {
+		var ran atomic.Bool
+		mgr := New("Exclusive boot sequence")
+		mgr.Register("one", func() error {
+			ran.Store(true)
+			return nil
+		}, NoOp)
+		mgr.Register("solo", NoOp, NoOp).Exclusive()
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "(solo) > (one)", agent.String())
+		verifyNilErr(t, agent.UpPrefix(context.Background(), "one", nil))
+		if !ran.Load() {
+			t.Fatal("expected one to run")
+		}
+	})
+
+	t.Run("it rejects exclusive services at the same priority", func(t *testing.T) {
+		mgr := New("Exclusive boot sequence")
+		mgr.Register("one", NoOp, NoOp).Exclusive()
+		mgr.Register("two", NoOp, NoOp).Exclusive()
+		err := mgr.Validate()
+		verifyErrorType(t, err, ExclusiveConflictError("one, two"))
+		verifyStringEquals(t, "exclusive services at the same priority: one, two", err.Error())
+	})
+}
+
+func TestServiceTransactionGroup(t *testing.T) {
+	t.Run("it rolls back members of a failed group", func(t *testing.T) {
+		var downs []string
+		var mu sync.Mutex
+		down := func(name string) Func {
+			return func() error {
+				mu.Lock()
+				downs = append(downs, name)
+				mu.Unlock()
+				return nil
+			}
+		}
+
+		mgr := New("Transactional boot sequence")
+		mgr.Register("one", NoOp, down("one")).TransactionGroup("tx")
+		mgr.Register("other", NoOp, down("other"))
+		mgr.Register("two", NoOp, down("two")).TransactionGroup("tx").After("one")
+		mgr.Register("three", ErrOp, down("three")).TransactionGroup("tx").After("one")
+		mgr.Register("four", NoOp, down("four")).TransactionGroup("tx").After("three")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		verifyStringsEqual(t, []string{"two", "one"}, downs)
+	})
+
+	t.Run("it leaves other groups alone", func(t *testing.T) {
+		var downs atomic.Int32
+		down := func() error {
+			downs.Add(1)
+			return nil
+		}
+
+		mgr := New("Transactional boot sequence")
+		mgr.Register("one", NoOp, down).TransactionGroup("first")
+		mgr.Register("two", ErrOp, down).TransactionGroup("second")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		verifyCountEq(t, uint32(downs.Load()), 0)
+	})
+
+	t.Run("it reports failed rollbacks", func(t *testing.T) {
+		mgr := New("Transactional boot sequence")
+		mgr.Register("one", NoOp, ErrOp).TransactionGroup("tx")
+		mgr.Register("two", ErrOp, NoOp).TransactionGroup("tx").After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var kinds []string
+		err = agent.Up(context.Background(), func(p Progress) {
+			kinds = append(kinds, p.Service+": "+p.Kind.String())
+		})
+		verifyErrorType(t, err, errService)
+		verifyStringsEqual(t, []string{"one: no error", "two: up error", "one: rollback error"}, kinds)
+	})
+}
+
+func TestManagerOneOf(t *testing.T) {
+	t.Run("it runs the picked member only", func(t *testing.T) {
+		var calls []string
+		var mu sync.Mutex
+		call := func(name string) Func {
+			return func() error {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				return nil
+			}
+		}
+
+		mgr := New("Cache boot sequence")
+		mgr.Register("redis", call("redis up"), call("redis down"))
+		mgr.Register("memcached", call("memcached up"), call("memcached down"))
+		mgr.Register("app", call("app up"), call("app down")).After("redis")
+		mgr.OneOf("cache", []string{"redis", "memcached"}, 0, 1)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		var skipped []string
+		err = agent.Up(context.Background(), func(p Progress) {
+			if p.Skipped {
+				skipped = append(skipped, p.Service)
+			}
+		})
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"redis"}, skipped)
+		verifyStringsEqual(t, []string{"memcached up", "app up"}, calls)
+
+		calls = nil
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+		verifyStringsEqual(t, []string{"app down", "memcached down"}, calls)
+	})
+
+	t.Run("it warms up the picked member only", func(t *testing.T) {
+		var warmed []string
+		var mu sync.Mutex
+		warm := func(name string) Func {
+			return func() error {
+				mu.Lock()
+				warmed = append(warmed, name)
+				mu.Unlock()
+				return nil
+			}
+		}
+
+		mgr := New("Cache boot sequence")
+		mgr.Register("redis", NoOp, NoOp).WithWarmup(warm("redis"))
+		mgr.Register("memcached", NoOp, NoOp).WithWarmup(warm("memcached"))
+		mgr.OneOf("cache", []string{"redis", "memcached"}, 0, 1)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyStringEquals(t, "memcached", strings.Join(warmed, ","))
+	})
+
+	t.Run("it picks exactly one member", func(t *testing.T) {
+		var ups atomic.Int32
+		up := func() error {
+			ups.Add(1)
+			return nil
+		}
+
+		mgr := New("Cache boot sequence")
+		mgr.Register("one", up, NoOp)
+		mgr.Register("two", up, NoOp)
+		mgr.Register("three", up, NoOp)
+		mgr.OneOf("numbers", []string{"one", "two", "three"})
+		for i := 0; i < 10; i++ {
+			agent, err := mgr.Agent()
+			verifyNilErr(t, err)
+			verifyNilErr(t, agent.Up(context.Background(), nil))
+		}
+		verifyCountEq(t, uint32(ups.Load()), 10)
+	})
+
+	t.Run("it validates groups", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			members []string
+			weights []int
+			err     error
+		}{
+			{"unknown member", []string{"one", "four"}, nil, UnregisteredServiceError("four")},
+			{"no members", nil, nil, InvalidChoiceError("group")},
+			{"missing weights", []string{"one", "two"}, []int{1}, InvalidChoiceError("group")},
+			{"negative weight", []string{"one", "two"}, []int{2, -1}, InvalidChoiceError("group")},
+			{"zero weights", []string{"one", "two"}, []int{0, 0}, InvalidChoiceError("group")},
+			{"repeated member", []string{"one", "one"}, nil, InvalidChoiceError("group")},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mgr := New("Cache boot sequence")
+				mgr.Register("one", NoOp, NoOp)
+				mgr.Register("two", NoOp, NoOp)
+				mgr.OneOf("group", tt.members, tt.weights...)
+				_, err := mgr.Agent()
+				verifyErrorType(t, err, tt.err)
+			})
+		}
+	})
+}
+
+func TestAgentCompleted(t *testing.T) {
+	t.Run("it reports services that came up before a failure", func(t *testing.T) {
+		mgr := New("Completed")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", ErrOp, NoOp).After("one")
+		mgr.Register("three", PanicOp, NoOp).After("two") // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		if agent.Completed("one") {
+			t.Fatal("expected no services to have completed before startup")
+		}
+
+		verifyErrorType(t, agent.Up(context.Background(), nil), errService)
+		for name, expected := range map[string]bool{"one": true, "two": false, "three": false, "four": false} {
+			if actual := agent.Completed(name); actual != expected {
+				t.Fatalf("expected Completed(%q) to return %t, got %t", name, expected, actual)
+			}
+		}
+	})
+
+	t.Run("it is kept after shutdown", func(t *testing.T) {
+		mgr := New("Completed")
+		mgr.Register("one", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		verifyNilErr(t, agent.Down(context.Background(), nil))
+		if !agent.Completed("one") {
+			t.Fatal("expected service to have completed")
+		}
+	})
+}
+
+func TestAgentCanUpCanDown(t *testing.T) {
+	mgr := New("Guarded boot sequence")
+	mgr.Register("one", NoOp, NoOp)
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	verify := func(when string, canUp, canDown bool) {
+		t.Helper()
+		if agent.CanUp() != canUp {
+			t.Fatalf("expected CanUp() to be %t %s", canUp, when)
+		}
+		if agent.CanDown() != canDown {
+			t.Fatalf("expected CanDown() to be %t %s", canDown, when)
+		}
+	}
+
+	verify("before startup", true, false)
+	err = agent.Up(context.Background(), func(Progress) {
+		verify("during startup", false, false)
+	})
+	verifyNilErr(t, err)
+	verify("after startup", false, true)
+	err = agent.Down(context.Background(), func(Progress) {
+		verify("during shutdown", false, false)
+	})
+	verifyNilErr(t, err)
+	verify("after shutdown", false, false)
+}
+
+func TestAgentSnapshot(t *testing.T) {
+	mgr := New("Snapshot boot sequence")
+	mgr.Register("one", NoOp, NoOp)
+	mgr.Register("two", NoOp, ErrOp).After("one")
+	mgr.Register("three", NoOp, NoOp).After("one")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	snapshot := agent.Snapshot()
+	verifyStringEquals(t, "Snapshot boot sequence", snapshot.Name)
+	verifyStringEquals(t, "idle", snapshot.State)
+	verifyStringsEqual(t, []string{}, snapshot.Completed)
+	if snapshot.Done {
+		t.Fatal("expected idle snapshot not to be done")
+	}
+
+	var during []AgentSnapshot
+	err = agent.Up(context.Background(), func(p Progress) {
+		if p.Service == "one" {
+			during = append(during, agent.Snapshot())
+		}
+	})
+	verifyNilErr(t, err)
+	if len(during) != 1 || during[0].Done {
+		t.Fatalf("expected a single snapshot taken while in progress, got %v", during)
+	}
+	verifyStringsEqual(t, []string{"one"}, during[0].Completed)
+
+	snapshot = agent.Snapshot()
+	verifyStringEquals(t, "up", snapshot.State)
+	verifyStringsEqual(t, []string{"one", "three", "two"}, snapshot.Completed)
+	if !snapshot.Done {
+		t.Fatal("expected snapshot to be done")
+	}
+
+	snapshot.Completed[0] = "changed"
+	verifyStringsEqual(t, []string{"one", "three", "two"}, agent.Snapshot().Completed)
+
+	err = agent.Down(context.Background(), nil)
+	verifyErrorType(t, err, errService)
+	snapshot = agent.Snapshot()
+	verifyStringEquals(t, "down", snapshot.State)
+	verifyStringsEqual(t, []string{"three"}, snapshot.Completed)
+}
+
+func TestAgentTimeline(t *testing.T) {
+	sleep := func(d time.Duration) Func {
+		return func() error {
+			time.Sleep(d)
+			return nil
+		}
+	}
+
+	mgr := New("Timed boot sequence")
+	mgr.Register("fast", NoOp, NoOp)
+	mgr.Register("slow", sleep(20*time.Millisecond), NoOp)
+	mgr.Register("next", sleep(time.Millisecond), NoOp).After("slow")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+
+	if len(agent.Timeline()) != 0 {
+		t.Fatalf("expected an empty timeline before running, got %v", agent.Timeline())
+	}
+
+	err = agent.Up(context.Background(), nil)
+	verifyNilErr(t, err)
+	timeline := agent.Timeline()
+	if len(timeline) != 3 {
+		t.Fatalf("expected 3 services in timeline, got %v", timeline)
+	}
+
+	byName := make(map[string]ServiceTiming)
+	for i, timing := range timeline {
+		byName[timing.Service] = timing
+		if timing.End.Before(timing.Start) {
+			t.Fatalf("expected %s to end after it started", timing.Service)
+		}
+		if i > 0 && timing.Start.Before(timeline[i-1].Start) {
+			t.Fatalf("expected timeline to be ordered by start time, got %v", timeline)
+		}
+	}
+	verifyStringEquals(t, "next", timeline[2].Service)
+	verifyCountEq(t, uint32(byName["slow"].Priority), 1)
+	verifyCountEq(t, uint32(byName["next"].Priority), 2)
+	if byName["next"].Start.Before(byName["slow"].End) {
+		t.Fatal("expected next to start after slow has ended")
+	}
+	if byName["slow"].End.Sub(byName["slow"].Start) < 20*time.Millisecond {
+		t.Fatalf("expected slow to take at least 20ms, got %s", byName["slow"].End.Sub(byName["slow"].Start))
+	}
+}
+
+func TestAgentRunJSON(t *testing.T) {
+	t.Run("it summarises the most recent run", func(t *testing.T) {
+		mgr := New("Reported boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", ErrOp, NoOp).After("one")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		_ = agent.Up(context.Background(), nil)
+		doc, err := agent.RunJSON()
+		verifyNilErr(t, err)
+
+		var r runReport
+		err = json.Unmarshal(doc, &r)
+		verifyNilErr(t, err)
+		verifyStringEquals(t, "Reported boot sequence", r.Name)
+		verifyStringEquals(t, "up", r.Phase)
+		if r.End == nil || r.End.Before(r.Start) {
+			t.Fatalf("expected end after start, got %v and %v", r.Start, r.End)
+		}
+		if len(r.Services) != 2 {
+			t.Fatalf("expected 2 services, got %v", r.Services)
+		}
+		verifyStringEquals(t, "one", r.Services[0].Name)
+		verifyStringEquals(t, "", r.Services[0].Err)
+		verifyStringEquals(t, "two", r.Services[1].Name)
+		verifyStringEquals(t, errService.Error(), r.Services[1].Err)
+	})
+
+	t.Run("it returns an error if no sequence has run", func(t *testing.T) {
+		mgr := New("Reported boot sequence")
+		mgr.Register("one", NoOp, NoOp)
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		_, err = agent.RunJSON()
+		verifyErrorType(t, err, InvalidStateError(idleErrorMessage))
+	})
+}
+
+func TestManagerRegisterContext(t *testing.T) {
+	t.Run("it passes the context of the sequence", func(t *testing.T) {
+		type key struct{}
+		var ups, downs []any
+		mgr := New("Context")
+		mgr.RegisterContext("one", func(ctx context.Context) error {
+			ups = append(ups, ctx.Value(key{}))
+			return nil
+		}, func(ctx context.Context) error {
+			downs = append(downs, ctx.Value(key{}))
+			return nil
+		})
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.WithValue(context.Background(), key{}, "up"), nil))
+		verifyNilErr(t, agent.Down(context.WithValue(context.Background(), key{}, "down"), nil))
+		if len(ups) != 1 || ups[0] != "up" || len(downs) != 1 || downs[0] != "down" {
+			t.Fatalf("expected each function to receive its context, got %v and %v", ups, downs)
+		}
+	})
+
+	t.Run("it rejects nil funcs", func(t *testing.T) {
+		mgr := New("Context")
+		mgr.RegisterContext("one", nil, func(context.Context) error { return nil })
+		_, err := mgr.Agent()
+		verifyErrorType(t, err, NilFuncError("one"))
+	})
+}
+
+func TestBudgetFromContext(t *testing.T) {
+	t.Run("it reports the remaining budget", func(t *testing.T) {
+		var (
+			lock      sync.Mutex
+			budgets   = make(map[string]int)
+			remaining time.Duration
+		)
+		budget := func(name string) ContextFunc {
+			return func(ctx context.Context) error {
+				r, ahead, ok := BudgetFromContext(ctx)
+				if !ok {
+					return errors.New("expected a budget")
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				budgets[name], remaining = ahead, r
+				return nil
+			}
+		}
+		mgr := New("Budget")
+		mgr.RegisterContext("one", budget("one"), budget("one"))
+		mgr.RegisterContext("two", budget("two"), budget("two")).After("one")
+		mgr.RegisterContext("three", budget("three"), budget("three")).After("one")
+		mgr.RegisterContext("four", budget("four"), budget("four")).After("two")
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		verifyNilErr(t, agent.Up(ctx, nil))
+		for name, expected := range map[string]int{"one": 3, "two": 1, "three": 1, "four": 0} {
+			if budgets[name] != expected {
+				t.Fatalf("expected %d services ahead of %q, got %d", expected, name, budgets[name])
+			}
+		}
+		if remaining <= 0 || remaining > time.Minute {
+			t.Fatalf("expected remaining time within a minute, got %s", remaining)
+		}
+
+		verifyNilErr(t, agent.Down(ctx, nil))
+		for name, expected := range map[string]int{"one": 0, "two": 1, "three": 1, "four": 3} {
+			if budgets[name] != expected {
+				t.Fatalf("expected %d services ahead of %q during shutdown, got %d", expected, name, budgets[name])
+			}
+		}
+	})
+
+	t.Run("it requires a deadline", func(t *testing.T) {
+		var ok bool
+		mgr := New("Budget")
+		mgr.RegisterContext("one", func(ctx context.Context) error {
+			_, _, ok = BudgetFromContext(ctx)
+			return nil
+		}, func(context.Context) error { return nil })
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		if ok {
+			t.Fatal("expected no budget without a deadline")
+		}
+		if _, _, ok = BudgetFromContext(context.Background()); ok {
+			t.Fatal("expected no budget outside of a sequence")
+		}
+	})
+}
+
+func TestManagerRegisterResource(t *testing.T) {
+	t.Run("it passes the resource from up to down", func(t *testing.T) {
+		type pool struct{ closed bool }
+		var closed *pool
+
+		mgr := New("Resourceful boot sequence")
+		mgr.RegisterResource("db", func() (any, error) {
+			return &pool{}, nil
+		}, func(v any) error {
+			closed = v.(*pool)
+			closed.closed = true
+			return nil
+		})
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyNilErr(t, err)
+		err = agent.Down(context.Background(), nil)
+		verifyNilErr(t, err)
+		if closed == nil || !closed.closed {
+			t.Fatal("expected the down function to close the pool")
+		}
+		if _, ok := agent.State().Get("db"); ok {
+			t.Fatal("expected the resource to be removed from the state")
+		}
+	})
+
+	t.Run("it doesn't store the resource if up fails", func(t *testing.T) {
+		mgr := New("Resourceful boot sequence")
+		mgr.RegisterResource("db", func() (any, error) {
+			return "handle", errService
+		}, func(any) error { return nil })
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Up(context.Background(), nil)
+		verifyErrorType(t, err, errService)
+		if _, ok := agent.State().Get("db"); ok {
+			t.Fatal("expected no resource in the state")
+		}
+	})
+
+	t.Run("it returns an error for nil funcs", func(t *testing.T) {
+		mgr := New("Resourceful boot sequence")
+		mgr.RegisterResource("db", nil, func(any) error { return nil })
+		err := mgr.Validate()
+		verifyErrorType(t, err, NilFuncError("db"))
+	})
+}
 
-	// First agent.
+func TestAgentConcurrentWith(t *testing.T) {
+	mgr := New("Boot it!")
+	mgr.Register("db", NoOp, NoOp)
+	mgr.Register("cache", NoOp, NoOp)
+	mgr.Register("queue", NoOp, NoOp)
+	mgr.Register("api", NoOp, NoOp).After("db")
 	agent, err := mgr.Agent()
 	verifyNilErr(t, err)
-	err = agent.Up(context.Background(), updater1.progress())
+
+	verifyStringsEqual(t, []string{"cache", "queue"}, agent.ConcurrentWith("db"))
+	verifyStringsEqual(t, []string{}, agent.ConcurrentWith("api"))
+	if agent.ConcurrentWith("unknown") != nil {
+		t.Fatal("expected nil for an unknown service")
+	}
+
+	serial, err := mgr.WithMaxConcurrency(1).Agent()
 	verifyNilErr(t, err)
-	verifyStringsEqual(t, []string{"one", "two", "three", ""}, updater1.actual)
+	if serial.ConcurrentWith("db") != nil {
+		t.Fatal("expected nil when services run one at a time")
+	}
+}
 
-	// Second agent.
-	mgr.Register("four", NoOp, NoOp)
+func TestAgentIsParallel(t *testing.T) {
+	mgr := New("Boot it!")
+	mgr.Register("db", NoOp, NoOp)
+	mgr.Register("api", NoOp, NoOp).After("db")
+	agent, err := mgr.Agent()
+	verifyNilErr(t, err)
+	if agent.IsParallel() {
+		t.Fatal("expected a chain of services not to be parallel")
+	}
+
+	mgr.Register("cache", NoOp, NoOp)
 	agent, err = mgr.Agent()
 	verifyNilErr(t, err)
-	err = agent.Up(context.Background(), updater2.progress())
+	if !agent.IsParallel() {
+		t.Fatal("expected services at the same priority to be parallel")
+	}
+
+	serial, err := mgr.WithMaxConcurrency(1).Agent()
 	verifyNilErr(t, err)
-	verifyStringsEqual(t, []string{"one", "two", "three", "four", ""}, updater2.actual)
+	if serial.IsParallel() {
+		t.Fatal("expected services that run one at a time not to be parallel")
+	}
 }
 
-func TestAgentServiceCount(t *testing.T) {
-	mgr := New("A Boot Sequence")
-	mgr.Register("one", NoOp, NoOp)
-
+func TestAgentTree(t *testing.T) {
+	mgr := New("Boot it!")
+	mgr.Register("queue", NoOp, NoOp)
+	mgr.Register("db", NoOp, NoOp)
+	mgr.Register("cache", NoOp, NoOp).After("db")
+	mgr.Register("api", NoOp, NoOp).After("queue")
+	mgr.Register("web", NoOp, NoOp).After("cache")
 	agent, err := mgr.Agent()
 	verifyNilErr(t, err)
-	verifyCountEq(t, 1, uint32(agent.ServiceCount()))
 
-	mgr.Register("two", NoOp, NoOp)
-	mgr.Register("three", NoOp, NoOp).After("two")
+	expected := "db\nqueue\n  api\n  cache\n    web"
+	verifyStringEquals(t, expected, agent.Tree())
+}
 
-	agent, err = mgr.Agent()
+func TestAgentServiceNames(t *testing.T) {
+	mgr := New("Boot it!")
+	mgr.Register("queue", NoOp, NoOp)
+	mgr.Register("db", NoOp, NoOp)
+	mgr.Register("cache", NoOp, NoOp).After("db")
+	mgr.Register("api", NoOp, NoOp).After("queue")
+	mgr.Register("web", NoOp, NoOp).After("cache")
+	agent, err := mgr.Agent()
 	verifyNilErr(t, err)
-	verifyCountEq(t, 3, uint32(agent.ServiceCount()))
 
-	mgr.Register("four", NoOp, NoOp).After("three")
-	mgr.Register("five", NoOp, NoOp).After("three")
+	expected := "db,queue,api,cache,web"
+	actual := strings.Join(agent.ServiceNames(), ",")
+	verifyStringEquals(t, expected, actual)
+}
 
-	agent, err = mgr.Agent()
+func TestAgentLinearize(t *testing.T) {
+	mgr := New("Linear boot sequence")
+	mgr.Register("db", NoOp, NoOp)
+	mgr.Register("config", NoOp, NoOp)
+	mgr.Register("cache", NoOp, NoOp).After("db")
+	mgr.Register("api", NoOp, NoOp).After("config")
+	mgr.Register("auth", NoOp, NoOp).After("cache")
+	agent, err := mgr.Agent()
 	verifyNilErr(t, err)
-	verifyCountEq(t, 5, uint32(agent.ServiceCount()))
+
+	expected := "config,api,db,cache,auth"
+	actual := strings.Join(agent.Linearize(), ",")
+	verifyStringEquals(t, expected, actual)
 }
 
-func TestAgentUp(t *testing.T) {
-	t.Run("it runs all services", func(t *testing.T) {
-		mgr := New("Three-service boot sequence")
+func TestRun(t *testing.T) {
+	t.Run("it boots, waits and shuts down", func(t *testing.T) {
+		mgr := New("Lifecycle")
 		mgr.Register("one", NoOp, NoOp)
-		mgr.Register("two", NoOp, NoOp)
-		mgr.Register("three", NoOp, NoOp)
-		agent, err := mgr.Agent()
-		verifyNilErr(t, err)
+		mgr.Register("two", NoOp, NoOp).After("one")
 
-		updater := newIndexUpdater(4)
-		err = agent.Up(context.Background(), updater.progress())
+		ctx, cancel := context.WithCancel(context.Background())
+		var (
+			lock  sync.Mutex
+			names []string
+		)
+		err := Run(ctx, mgr, func(p Progress) {
+			lock.Lock()
+			defer lock.Unlock()
+			names = append(names, p.Service)
+			if len(names) == 3 {
+				cancel() // Interrupt once the startup sequence has completed.
+			}
+		})
 		verifyNilErr(t, err)
-		verifyStringsEqual(t, []string{"one", "two", "three", ""}, updater.actual)
+		verifyStringEquals(t, "one,two,<done>,two,one,<done>", strings.Join(names, ","))
 	})
 
-	t.Run("it runs dependent services in chronological order", func(t *testing.T) {
-		mgr := New("Three-service boot sequence")
+	t.Run("it returns validation errors", func(t *testing.T) {
+		err := Run(context.Background(), New("Empty"), nil)
+		verifyErrorType(t, err, EmptySequenceError("Empty"))
+	})
+
+	t.Run("it returns startup errors", func(t *testing.T) {
+		mgr := New("Lifecycle")
+		mgr.Register("one", ErrOp, PanicOp) // PanicOp should never execute.
+		err := Run(context.Background(), mgr, nil)
+		verifyErrorType(t, err, errService)
+	})
+	t.Run("it shuts down the services that came up before a failure", func(t *testing.T) {
+		mgr := New("Lifecycle")
+		mgr.Register("one", NoOp, NoOp)
+		mgr.Register("two", ErrOp, PanicOp).After("one") // PanicOp should never execute.
+		var names []string
+		err := Run(context.Background(), mgr, func(p Progress) { names = append(names, p.Service) })
+		verifyErrorType(t, err, errService)
+		verifyStringEquals(t, "one,two,one,<done>", strings.Join(names, ","))
+	})
+}
+
+func TestAgentRun(t *testing.T) {
+	t.Run("it shuts down once the ready channel is closed", func(t *testing.T) {
+		mgr := New("Lifecycle")
 		mgr.Register("one", NoOp, NoOp)
 		mgr.Register("two", NoOp, NoOp).After("one")
-		mgr.Register("three", NoOp, NoOp).After("two")
 		agent, err := mgr.Agent()
 		verifyNilErr(t, err)
 
-		updater := newIndexUpdater(4)
-		err = agent.Up(context.Background(), updater.progress())
+		var ups, downs []string
+		err = agent.Run(context.Background(), func() <-chan struct{} {
+			stop := make(chan struct{})
+			close(stop)
+			return stop
+		}, func(p Progress) {
+			ups = append(ups, p.Service)
+		}, func(p Progress) {
+			downs = append(downs, p.Service)
+		})
 		verifyNilErr(t, err)
-		orderPreserved := verifyStringsEqual(t, []string{"one", "two", "three", ""}, updater.actual)
-		verifyOrderPreserved(t, orderPreserved)
+		verifyStringEquals(t, "one,two,<done>", strings.Join(ups, ","))
+		verifyStringEquals(t, "two,one,<done>", strings.Join(downs, ","))
 	})
 
-	t.Run("it runs services in chronological order (advanced case)", func(t *testing.T) {
-		mgr := New("Boot it!")
-		mgr.Register("one", NoOp, NoOp)
-		mgr.Register("two", NoOp, ErrOp).After("one")
-		mgr.Register("three", NoOp, NoOp).After("two")
-		mgr.Register("four", NoOp, NoOp).After("three")
-		mgr.Register("five", ErrOp, NoOp).After("four")  // Fails on fifth "up" Service.
-		mgr.Register("six", PanicOp, NoOp).After("five") // PanicOp should never execute.
+	t.Run("it shuts down once the context is cancelled", func(t *testing.T) {
+		mgr := New("Lifecycle")
+		mgr.Register("one", NoOp, ErrOp)
 		agent, err := mgr.Agent()
 		verifyNilErr(t, err)
 
-		updater := newIndexUpdater(7)
-
-		err = agent.Up(context.Background(), updater.progress())
+		ctx, cancel := context.WithCancel(context.Background())
+		err = agent.Run(ctx, func() <-chan struct{} {
+			cancel()
+			return make(chan struct{}) // Never closed.
+		}, nil, nil)
 		verifyErrorType(t, err, errService)
-		orderPreserved := verifyStringsEqual(t, []string{"one", "two", "three", "four", "five"}, updater.actual)
-		verifyOrderPreserved(t, orderPreserved)
 	})
 
-	t.Run("it fails if called while booting up", func(t *testing.T) {
-		mgr := New("Three-service boot sequence")
-		mgr.Register("one", SleepOp, NoOp)
-		mgr.Register("two", SleepOp, NoOp).After("one")
-		mgr.Register("three", SleepOp, NoOp).After("two")
-		mgr.Register("four", SleepOp, NoOp).After("three")
-		mgr.Register("five", SleepOp, NoOp).After("four")
-		mgr.Register("six", SleepOp, NoOp).After("five")
+	t.Run("it returns startup errors", func(t *testing.T) {
+		mgr := New("Lifecycle")
+		mgr.Register("one", ErrOp, PanicOp) // PanicOp should never execute.
+		agent, err := mgr.Agent()
+		verifyNilErr(t, err)
+
+		err = agent.Run(context.Background(), func() <-chan struct{} {
+			t.Fatal("expected onReady not to be called")
+			return nil
+		}, nil, nil)
+		verifyErrorType(t, err, errService)
+	})
+	t.Run("it shuts down the services that came up before a failure", func(t *testing.T) {
+		errDown := errors.New("down has failed")
+		mgr := New("Lifecycle")
+		mgr.Register("one", NoOp, func() error { return errDown })
+		mgr.Register("two", NoOp, NoOp)
+		mgr.Register("three", ErrOp, PanicOp).After("one") // PanicOp should never execute.
 		agent, err := mgr.Agent()
 		verifyNilErr(t, err)
 
 		var (
-			err1, err2 error
-			wg         sync.WaitGroup
+			lock  sync.Mutex
+			downs []string
 		)
-
-		wg.Add(2)
-		go func() {
-			err1 = agent.Up(context.Background(), nil)
-			wg.Done()
-		}()
-
-		go func() {
-			err2 = agent.Up(context.Background(), nil)
-			wg.Done()
-		}()
-
-		wg.Wait()
-		if err1 == nil && err2 == nil {
-			t.Error("expected err1 or err2 to be non-nil")
+		err = agent.Run(context.Background(), nil, nil, func(p Progress) {
+			lock.Lock()
+			defer lock.Unlock()
+			downs = append(downs, p.Service)
+		})
+		if !errors.Is(err, errService) || !errors.Is(err, errDown) {
+			t.Fatalf("expected both the startup and shutdown errors, got %v", err)
 		}
+		sort.Strings(downs)
+		verifyStringEquals(t, "one,two", strings.Join(downs, ","))
 	})
-}
 
-func TestAgentDown(t *testing.T) {
-	t.Run("it runs all services", func(t *testing.T) {
-		mgr := New("Three-service boot sequence")
-		mgr.Register("one", NoOp, NoOp)
-		mgr.Register("two", NoOp, NoOp)
-		mgr.Register("three", NoOp, NoOp)
+	t.Run("it leaves an agent in use alone", func(t *testing.T) {
+		mgr := New("Lifecycle")
+		mgr.Register("one", NoOp, PanicOp) // PanicOp should never execute.
 		agent, err := mgr.Agent()
 		verifyNilErr(t, err)
 
-		updater1 := newIndexUpdater(4)
-		err = agent.Up(context.Background(), updater1.progress())
-		verifyNilErr(t, err)
-
-		updater2 := newIndexUpdater(4)
-		err = agent.Down(context.Background(), updater2.progress())
-		verifyNilErr(t, err)
-		verifyStringsEqual(t, []string{"one", "two", "three", ""}, updater2.actual)
+		verifyNilErr(t, agent.Up(context.Background(), nil))
+		err = agent.Run(context.Background(), nil, nil, nil)
+		verifyErrorType(t, err, InvalidStateError(inProgressErrorMessage))
 	})
+}
 
-	t.Run("it runs services in reverse chronological order", func(t *testing.T) {
-		mgr := New("Three-service boot sequence")
+func TestRecordRun(t *testing.T) {
+	t.Run("it records every event in order", func(t *testing.T) {
+		mgr := New("Recorded")
 		mgr.Register("one", NoOp, NoOp)
 		mgr.Register("two", NoOp, NoOp).After("one")
-		mgr.Register("three", NoOp, NoOp).After("two")
 		agent, err := mgr.Agent()
 		verifyNilErr(t, err)
 
-		updater1 := newIndexUpdater(4)
-		err = agent.Up(context.Background(), updater1.progress())
-		verifyNilErr(t, err)
-
-		updater2 := newIndexUpdater(4)
-		err = agent.Down(context.Background(), updater2.progress())
+		events, err := RecordRun(context.Background(), agent)
 		verifyNilErr(t, err)
-		orderPreserved := verifyStringsEqual(t, []string{"three", "two", "one", ""}, updater2.actual)
-		verifyOrderPreserved(t, orderPreserved)
+		names := make([]string, len(events))
+		for i, p := range events {
+			names[i] = p.Service
+		}
+		verifyStringEquals(t, "one,two,<done>", strings.Join(names, ","))
 	})
 
-	t.Run("it runs services in reverse order (advanced case)", func(t *testing.T) {
-		mgr := New("Boot it!")
-		mgr.Register("one", NoOp, PanicOp)            // PanicOp should never execute.
-		mgr.Register("two", NoOp, ErrOp).After("one") // Fails on fifth Down service.
-		mgr.Register("three", NoOp, NoOp).After("two")
-		mgr.Register("four", NoOp, NoOp).After("three")
-		mgr.Register("five", NoOp, NoOp).After("four")
-		mgr.Register("six", NoOp, NoOp).After("five")
+	t.Run("it records events up to the error", func(t *testing.T) {
+		mgr := New("Recorded")
+		mgr.Register("one", ErrOp, NoOp)
+		mgr.Register("two", PanicOp, NoOp).After("one") // PanicOp should never execute.
 		agent, err := mgr.Agent()
 		verifyNilErr(t, err)
 
-		updater1 := newIndexUpdater(7)
-		err = agent.Up(context.Background(), updater1.progress())
-		verifyNilErr(t, err)
-
-		updater2 := newIndexUpdater(7)
-		err = agent.Down(context.Background(), updater2.progress())
+		events, err := RecordRun(context.Background(), agent)
 		verifyErrorType(t, err, errService)
-		orderPreserved := verifyStringsEqual(t, []string{"six", "five", "four", "three", "two"}, updater2.actual)
-		verifyOrderPreserved(t, orderPreserved)
-	})
-
-	t.Run("it fails if called while booting up", func(t *testing.T) {
-		mgr := New("Three-service boot sequence")
-		mgr.Register("one", SleepOp, NoOp)
-		mgr.Register("two", SleepOp, NoOp)
-		mgr.Register("three", SleepOp, NoOp)
-		agent, err := mgr.Agent()
-		verifyNilErr(t, err)
-
-		var (
-			err1, err2 error
-			wg         sync.WaitGroup
-		)
-
-		wg.Add(2)
-		func() {
-			err1 = agent.Up(context.Background(), nil)
-			wg.Done()
-		}()
-
-		func() {
-			err2 = agent.Up(context.Background(), nil)
-			wg.Done()
-		}()
-
-		wg.Wait()
-		if err1 == nil && err2 == nil {
-			t.Error("expected err1 or err2 to be non-nil")
-		}
+		verifyCountEq(t, 1, uint32(len(events)))
+		verifyErrorType(t, events[0].Err, errService)
 	})
 }
 
-func TestAgentCancel(t *testing.T) {
-	t.Run("it stops before executing all services", func(t *testing.T) {
-		mgr := New("Boot it!")
-		mgr.Register("one", SleepOp, NoOp)
-		mgr.Register("two", SleepOp, NoOp).After("one")
-		mgr.Register("three", SleepOp, NoOp).After("two")
-		mgr.Register("four", SleepOp, NoOp).After("three")
-		mgr.Register("five", SleepOp, NoOp).After("four")
-		mgr.Register("six", PanicOp, NoOp).After("five")
-		agent, err := mgr.Agent()
-		verifyNilErr(t, err)
-
-		ctx, cancel := context.WithCancel(context.Background())
-		updater := newIndexUpdater(7)
-
-		var wg sync.WaitGroup
-		wg.Add(1)
-		go func() {
-			err = agent.Up(ctx, updater.progress())
-			verifyErrorType(t, err, context.Canceled)
-			wg.Done()
-		}()
-		cancel()
+func TestReplay(t *testing.T) {
+	events := []Progress{{Service: "one"}, {Service: "two"}, {Service: DoneService}}
 
-		wg.Wait()
-		for _, a := range updater.actual {
-			if a == "five" {
-				// Execution should stop long before reaching the fifth service.
-				t.Error("did not expect to encounter service five due to cancellation")
-			}
-		}
+	t.Run("it replays every event in order", func(t *testing.T) {
+		var names []string
+		Replay(events, func(p Progress) {
+			names = append(names, p.Service)
+		}, 0)
+		verifyStringEquals(t, "one,two,<done>", strings.Join(names, ","))
 	})
-}
-
-func TestAgentTimeout(t *testing.T) {
-	t.Run("it stops before executing all services", func(t *testing.T) {
-		mgr := New("Boot it!")
-		mgr.Register("one", SleepOp, NoOp)
-		mgr.Register("two", SleepOp, NoOp).After("one")
-		mgr.Register("three", SleepOp, NoOp).After("two")
-		mgr.Register("four", SleepOp, NoOp).After("three")
-		mgr.Register("five", SleepOp, NoOp).After("four")
-		mgr.Register("six", PanicOp, NoOp).After("five")
-		agent, err := mgr.Agent()
-		verifyNilErr(t, err)
-
-		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-		defer cancel()
-		updater := newIndexUpdater(7)
-		err = agent.Up(ctx, updater.progress())
-		verifyErrorType(t, err, context.DeadlineExceeded)
 
-		for _, a := range updater.actual {
-			if a == "five" {
-				// Execution should stop long before reaching the fifth service.
-				t.Fatal("did not expect to encounter service five due to cancellation")
-			}
+	t.Run("it waits between events", func(t *testing.T) {
+		start := time.Now()
+		Replay(events, func(Progress) {}, 10*time.Millisecond)
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Fatalf("expected replay to take at least 20ms, took %s", elapsed)
 		}
 	})
 }
@@ -757,3 +4104,37 @@ func TestAgentString(t *testing.T) {
 		verifyStringEquals(t, expected, actual)
 	})
 }
+
+// BenchmarkAgentUp compares startup sequences with and without a progress function. Neither allocates a Progress per
+// Service, so they should allocate about the same.
+func BenchmarkAgentUp(b *testing.B) {
+	mgr := New("Benchmark")
+	for _, worker := range mgr.RegisterN("worker", 100, NoOp, NoOp) {
+		worker.After("queue")
+	}
+	mgr.Register("queue", NoOp, NoOp)
+
+	b.Run("unobserved", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			agent, _ := mgr.Agent()
+			b.StartTimer()
+			if err := agent.Up(context.Background(), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("observed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			agent, _ := mgr.Agent()
+			b.StartTimer()
+			if err := agent.Up(context.Background(), func(Progress) {}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package bootseq
+
+import "errors"
+
+// Builder registers a Service like Manager.Register, but validates its order of execution as soon as it's declared;
+// see Manager.RegisterStrict. Errors are accumulated rather than returned by each method, so that calls can be
+// chained, and are returned by Builder.Err.
+type Builder struct {
+	m    *Manager
+	srvc *Service
+	errs []error
+}
+
+// RegisterStrict registers a single named Service like Register, and returns a Builder for declaring its order of
+// execution. Unlike Service.After, Builder.After requires the referenced Service to have been registered already,
+// which catches mistakes at the point of definition rather than during validation. Use Register for Services that
+// intentionally refer to Services that are registered later on.
+func (m *Manager) RegisterStrict(name string, up, down Func) *Builder {
+	return &Builder{m: m, srvc: m.Register(name, up, down)}
+}
+
+// After sets the Service of the Builder to be executed after the one defined by the given name, like Service.After.
+// It records a SelfReferenceError if the name is that of the Service itself, or an UnregisteredServiceError if no
+// Service with the given name has been registered with the Manager yet. The Service is set to come after the named
+// one regardless, so that ignoring the error still fails validation. After returns the receiver to allow for chaining.
+func (b *Builder) After(name string) *Builder {
+	b.m.lock.Lock()
+	_, ok := b.m.services[name]
+	b.m.lock.Unlock()
+
+	switch {
+	case name == b.srvc.name:
+		b.errs = append(b.errs, SelfReferenceError(name))
+	case !ok:
+		b.errs = append(b.errs, UnregisteredServiceError(name))
+	}
+	b.srvc.After(name)
+	return b
+}
+
+// Service returns the registered Service, which allows for setting its other properties, such as tags.
+func (b *Builder) Service() *Service {
+	return b.srvc
+}
+
+// Err returns the errors recorded by the Builder joined together, or nil if there are none.
+func (b *Builder) Err() error {
+	return errors.Join(b.errs...)
+}
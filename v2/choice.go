@@ -0,0 +1,124 @@
+package bootseq
+
+import "math/rand"
+
+// choice is a group of interchangeable Services, of which only one runs during each startup sequence; see
+// Manager.OneOf.
+type choice struct {
+	group   string
+	members []string
+	weights []int // One per member; nil means equal weights.
+}
+
+// weight returns the weight of the member at index i.
+func (c choice) weight(i int) int {
+	if c.weights == nil {
+		return 1
+	}
+	return c.weights[i]
+}
+
+// pick returns the name of a randomly chosen member for which has returns true, with a probability proportional to
+// its weight. pick returns an empty string if no such member has a weight greater than zero.
+func (c choice) pick(has func(name string) bool) string {
+	total := 0
+	for i, member := range c.members {
+		if has(member) {
+			total += c.weight(i)
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	n := rand.Intn(total)
+	for i, member := range c.members {
+		if !has(member) {
+			continue
+		}
+		if n -= c.weight(i); n < 0 {
+			return member
+		}
+	}
+	return ""
+}
+
+// OneOf places the Services with the given names in a group of interchangeable providers, such as alternative cache
+// backends, of which only one runs during each startup sequence. The member to run is picked at random, with a
+// probability proportional to its weight. Weights are optional, but if given, there must be one per member, and none
+// of them may be negative. A member with a weight of zero is never picked. Members that aren't picked are reported
+// with Progress.Skipped set, and are never shut down, while Services that come after any of the members proceed
+// normally. Groups are validated during Agent instantiation, which returns an InvalidChoiceError for malformed
+// groups, or an UnregisteredServiceError for unknown members.
+// OneOf only affects Agents that are instantiated after the call. It returns the Manager to allow for chaining.
+func (m *Manager) OneOf(group string, members []string, weights ...int) *Manager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reject(group) {
+		return m
+	}
+	c := choice{group: group, members: append([]string(nil), members...)}
+	if len(weights) > 0 {
+		c.weights = append([]int(nil), weights...)
+	}
+	m.opts.choices = append(append([]choice(nil), m.opts.choices...), c)
+	return m
+}
+
+// validateChoices checks the groups set with Manager.OneOf against the given Services. Each group must have at least
+// one member, a weight per member if any, no negative weights and a total weight greater than zero, and no Service
+// may be a member of more than one group.
+func validateChoices(choices []choice, services unorderedServices) error {
+	seen := make(map[string]bool)
+	for _, c := range choices {
+		if len(c.members) == 0 || (c.weights != nil && len(c.weights) != len(c.members)) {
+			return InvalidChoiceError(c.group)
+		}
+		total := 0
+		for i, member := range c.members {
+			if _, ok := services[member]; !ok {
+				return UnregisteredServiceError(member)
+			}
+			if seen[member] || c.weight(i) < 0 {
+				return InvalidChoiceError(c.group)
+			}
+			seen[member] = true
+			total += c.weight(i)
+		}
+		if total == 0 {
+			return InvalidChoiceError(c.group)
+		}
+	}
+	return nil
+}
+
+// choose picks a member of each group set with Manager.OneOf among the Services of the Agent, and marks the others
+// to be skipped during the current startup sequence. The caller must hold the lock.
+func (a *Agent) choose() {
+	has := make(map[string]bool)
+	for _, services := range a.orderedServices {
+		for _, service := range services {
+			has[service.name] = true
+		}
+	}
+
+	a.skipped = make(map[string]bool)
+	for _, c := range a.opts.choices {
+		picked := c.pick(func(name string) bool { return has[name] })
+		for _, member := range c.members {
+			if has[member] && member != picked {
+				a.skipped[member] = true
+			}
+		}
+	}
+}
+
+// isSkipped returns true if the Service with the given name wasn't picked from its group during the current startup
+// sequence; see Manager.OneOf.
+func (a *Agent) isSkipped(name string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.skipped[name]
+}
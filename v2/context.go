@@ -0,0 +1,69 @@
+package bootseq
+
+import (
+	"context"
+	"time"
+)
+
+// ContextFunc is the type used for Service functions that receive the context of the sequence running them, which
+// allows them to honour cancellation and deadlines. See Manager.RegisterContext.
+type ContextFunc func(ctx context.Context) error
+
+// budgetKey is the context key under which the number of Services ahead of the running one is stored; see
+// BudgetFromContext.
+type budgetKey struct{}
+
+// RegisterContext registers a single named Service like Register, but with functions that receive the context of the
+// sequence running them. The context is done once the sequence is cancelled or times out, and carries the budget of
+// the sequence; see BudgetFromContext.
+func (m *Manager) RegisterContext(name string, up, down ContextFunc) *Service {
+	return m.register(Service{name: name, upCtx: up, downCtx: down})
+}
+
+// BudgetFromContext returns the time remaining until the deadline of the sequence, and the number of Services that
+// are still ahead of the running one, ie. those in the priority groups that come after its own, when called with the
+// context passed to a ContextFunc. This allows a Service to adapt its own timeout to its share of the remaining time.
+// ok is false if ctx wasn't passed to a ContextFunc, or if it has no deadline.
+func BudgetFromContext(ctx context.Context) (remaining time.Duration, servicesAhead int, ok bool) {
+	ahead, found := ctx.Value(budgetKey{}).(int)
+	deadline, hasDeadline := ctx.Deadline()
+	if !found || !hasDeadline {
+		return 0, 0, false
+	}
+	return time.Until(deadline), ahead, true
+}
+
+// withBudget returns a copy of ctx that carries the number of Services ahead of the priority group with the given
+// priority, in the direction of the current sequence. During shutdown, only Services that were brought up count, and
+// during startup, Services that weren't picked from their group are left out; see Manager.OneOf.
+func (a *Agent) withBudget(ctx context.Context, priority uint16) context.Context {
+	ahead, after := 0, false
+	for _, p := range a.priorities(a.state) {
+		if !after {
+			after = p == priority
+			continue
+		}
+		for _, service := range a.orderedServices[p] {
+			if a.state == stateDown && !a.isStarted(service.name) || a.state == stateUp && a.isSkipped(service.name) {
+				continue
+			}
+			ahead++
+		}
+	}
+	return context.WithValue(ctx, budgetKey{}, ahead)
+}
+
+// funcWithContext returns the Func of the Service for the given state, which calls its ContextFunc with ctx, if any.
+func (s *Service) funcWithContext(ctx context.Context, ph state) Func {
+	var fn ContextFunc
+	switch ph {
+	case stateUp:
+		fn = s.upCtx
+	case stateDown:
+		fn = s.downCtx
+	}
+	if fn == nil {
+		return s.byState(ph)
+	}
+	return func() error { return fn(ctx) }
+}
@@ -1,10 +1,19 @@
 package bootseq
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrEmptyName indicates a boot sequence without a name; see New.
+var ErrEmptyName = errors.New("boot sequence has no name")
 
 const (
-	// panicServiceLimit triggers when client attempts to add step 65536 to the manager.
-	panicServiceLimit = "reached limit of max 65535 services"
+	// panicServiceLimit triggers when client attempts to add more services to the manager than its service limit
+	// allows. It's a format string for the limit.
+	panicServiceLimit = "reached limit of max %d services"
 
 	// panicUnknownState triggers when calling service.byState() with incorrect state.
 	panicUnknownState = "unknown state: must match stateUp or stateDown"
@@ -25,12 +34,12 @@ const (
 	doneErrorMessage = "has already shut down"
 )
 
-// EmptySequenceError indicates an empty boot sequence.
+// EmptySequenceError indicates a boot sequence without any registered services. It holds the name of the sequence.
 type EmptySequenceError string
 
 // Error returns the error message for a EmptySequenceError.
 func (e EmptySequenceError) Error() string {
-	return fmt.Sprintf("empty boot sequence: %q", string(e))
+	return fmt.Sprintf("no services registered in boot sequence %q", string(e))
 }
 
 // SelfReferenceError indicates a service that references itself in an After method call.
@@ -82,6 +91,160 @@ func (n NilFuncError) Error() string {
 	return fmt.Sprintf("nil Func provided: %s", string(n))
 }
 
+// ReservedNameError indicates that a Service was registered using a name reserved by the package, such as DoneService.
+type ReservedNameError string
+
+// Error returns the error message for a ReservedNameError.
+func (r ReservedNameError) Error() string {
+	return fmt.Sprintf("reserved service name: %q", string(r))
+}
+
+// InvalidPlanError indicates that a document describing a boot sequence could not be loaded.
+type InvalidPlanError string
+
+// Error returns the error message for an InvalidPlanError.
+func (i InvalidPlanError) Error() string {
+	return fmt.Sprintf("invalid plan: %s", string(i))
+}
+
+// ReverseOrderError indicates that the shutdown sequence isn't the exact reverse of the startup sequence.
+type ReverseOrderError string
+
+// Error returns the error message for a ReverseOrderError.
+func (r ReverseOrderError) Error() string {
+	return fmt.Sprintf("shutdown is not the reverse of startup: %s", string(r))
+}
+
+// AmbiguousReferenceError indicates that the references of a Service were resolved to more than one other Service.
+type AmbiguousReferenceError string
+
+// Error returns the error message for an AmbiguousReferenceError.
+func (a AmbiguousReferenceError) Error() string {
+	return fmt.Sprintf("service comes after more than one other: %q", string(a))
+}
+
+// UnknownTagError indicates that no Service has been tagged with any of the requested tags.
+type UnknownTagError string
+
+// Error returns the error message for an UnknownTagError.
+func (u UnknownTagError) Error() string {
+	return fmt.Sprintf("no services tagged with any of: %s", string(u))
+}
+
+// DuplicateServiceError indicates that a Service was registered with Manager.TryRegister using a name that has already
+// been registered.
+type DuplicateServiceError string
+
+// Error returns the error message for a DuplicateServiceError.
+func (d DuplicateServiceError) Error() string {
+	return fmt.Sprintf("service already registered: %q", string(d))
+}
+
+// SealedManagerError indicates that a Manager returned by Manager.Freeze, or a Manager that was sealed by instantiating
+// an Agent, was changed; see Manager.SealOnAgent. It holds the name of the Service, Manager method or OneOf group that
+// the rejected change was made to.
+type SealedManagerError string
+
+// Error returns the error message for a SealedManagerError.
+func (s SealedManagerError) Error() string {
+	return fmt.Sprintf("cannot change frozen manager: %q", string(s))
+}
+
+// InvalidChoiceError indicates that a group of interchangeable Services set with Manager.OneOf is malformed, such as a
+// group with negative weights, or a Service that is a member of more than one group. It holds the name of the group.
+type InvalidChoiceError string
+
+// Error returns the error message for an InvalidChoiceError.
+func (i InvalidChoiceError) Error() string {
+	return fmt.Sprintf("invalid choice group: %q", string(i))
+}
+
+// ExclusiveConflictError indicates that two exclusive Services are placed at the same priority; see Service.Exclusive.
+// It holds the names of both Services.
+type ExclusiveConflictError string
+
+// Error returns the error message for an ExclusiveConflictError.
+func (e ExclusiveConflictError) Error() string {
+	return fmt.Sprintf("exclusive services at the same priority: %s", string(e))
+}
+
+// AbandonedServicesError indicates that Services were still running when the grace period set with
+// Manager.WithCancelGrace expired. It holds the names of the Services, separated by commas.
+type AbandonedServicesError string
+
+// Error returns the error message for an AbandonedServicesError.
+func (a AbandonedServicesError) Error() string {
+	return fmt.Sprintf("abandoned services: %s", string(a))
+}
+
+// LevelTimeoutError indicates that a priority group didn't complete within the time allotted to it by
+// Agent.UpWithLevelTimeout. It holds the priority of the group, and unwraps to context.DeadlineExceeded.
+type LevelTimeoutError uint16
+
+// Error returns the error message for a LevelTimeoutError.
+func (l LevelTimeoutError) Error() string {
+	return fmt.Sprintf("priority group %d timed out: %s", uint16(l), context.DeadlineExceeded)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (l LevelTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// ServiceTimeoutError indicates that a call to a Func of a Service didn't complete within the timeout set with
+// Service.WithTimeout. It holds the name of the Service, and unwraps to context.DeadlineExceeded.
+type ServiceTimeoutError string
+
+// Error returns the error message for a ServiceTimeoutError.
+func (s ServiceTimeoutError) Error() string {
+	return fmt.Sprintf("service %q timed out: %s", string(s), context.DeadlineExceeded)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (s ServiceTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// ServiceError is the error returned by a Func of the named Service; see MultiError.
+type ServiceError struct {
+	Service string
+	Err     error
+}
+
+// Error returns the name of the Service followed by the error message.
+func (s ServiceError) Error() string {
+	return s.Service + ": " + s.Err.Error()
+}
+
+// Unwrap returns the error returned by the Service Func.
+func (s ServiceError) Unwrap() error {
+	return s.Err
+}
+
+// MultiError aggregates the errors of several Services, such as those returned by a best-effort shutdown sequence;
+// see Manager.WithBestEffortDown. The errors are ordered by the execution of their Services. errors.Is and errors.As
+// inspect every one of them.
+type MultiError []ServiceError
+
+// Error returns a numbered list of the errors, one per line, each prefixed by the name of its Service.
+func (m MultiError) Error() string {
+	lines := make([]string, len(m))
+	for i, err := range m {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the ServiceError of each Service, which in turn unwraps to the error returned by its Func. This allows
+// errors.As to find a ServiceError in the aggregate, and errors.Is to match the errors of the Funcs.
+func (m MultiError) Unwrap() []error {
+	errs := make([]error, len(m))
+	for i, err := range m {
+		errs[i] = err
+	}
+	return errs
+}
+
 // Check that errors satisfy the error interface.
 var _ error = EmptySequenceError("")
 var _ error = SelfReferenceError("")
@@ -90,3 +253,17 @@ var _ error = InvalidStateError("")
 var _ error = CyclicReferenceError("")
 var _ error = CalleeError("")
 var _ error = NilFuncError("")
+var _ error = ReservedNameError("")
+var _ error = InvalidPlanError("")
+var _ error = ReverseOrderError("")
+var _ error = AmbiguousReferenceError("")
+var _ error = UnknownTagError("")
+var _ error = DuplicateServiceError("")
+var _ error = SealedManagerError("")
+var _ error = InvalidChoiceError("")
+var _ error = ExclusiveConflictError("")
+var _ error = AbandonedServicesError("")
+var _ error = LevelTimeoutError(0)
+var _ error = ServiceTimeoutError("")
+var _ error = ServiceError{}
+var _ error = MultiError(nil)
@@ -44,11 +44,11 @@ func Example_basic_test() {
 	// to
 	// my
 	// world
-	//
+	// <done>
 	// Welcome to my world!
 	// world
 	// my
 	// to
 	// welcome
-	//
+	// <done>
 }
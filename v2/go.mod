@@ -1,12 +1,12 @@
 module github.com/mkock/bootseq/v2
 
-go 1.13
+go 1.23
 
 require (
 	github.com/client9/misspell v0.3.4 // indirect
 	github.com/gordonklaus/ineffassign v0.0.0-20210209182638-d0e41b2fc8ed // indirect
 	github.com/mdempsky/unconvert v0.0.0-20200228143138-95ecdbfc0b5f // indirect
-	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+	golang.org/x/sync v0.1.0
 	honnef.co/go/tools v0.1.1 // indirect
 	mvdan.cc/unparam v0.0.0-20210104141923-aac4ce9116a7 // indirect
 )
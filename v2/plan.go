@@ -0,0 +1,85 @@
+package bootseq
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// plan is the serialised form of a Manager's dependency structure.
+type plan struct {
+	Name     string        `json:"name"`
+	Services []planService `json:"services"`
+}
+
+// planService is the serialised form of a single Service.
+type planService struct {
+	Name  string   `json:"name"`
+	After []string `json:"after"`
+}
+
+// LoadManager reads a JSON document describing a boot sequence and returns a new Manager with the described Services.
+// The document contains the name of the boot sequence and a list of Services, each with a name and a list of names
+// of the Services it must run after:
+//
+//	{
+//		"name": "My Boot Sequence",
+//		"services": [
+//			{"name": "database", "after": []},
+//			{"name": "cache", "after": ["database"]}
+//		]
+//	}
+//
+// Services are registered with nil functions as placeholders. Register each Service again, using the same name, in
+// order to provide its functions; the order of execution defined in the document is kept. Validation fails with a
+// NilFuncError for any Service that still has placeholder functions.
+// At most one name is currently supported in each list of Services to run after.
+func LoadManager(r io.Reader) (*Manager, error) {
+	var p plan
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	mgr := New(p.Name)
+	mgr.placeholders = make(map[string]bool, len(p.Services))
+	for _, ps := range p.Services {
+		if _, ok := mgr.services[ps.Name]; ok {
+			return nil, InvalidPlanError("duplicate service " + ps.Name)
+		}
+		if len(ps.After) > 1 {
+			return nil, InvalidPlanError("service " + ps.Name + " must run after at most one service")
+		}
+		srvc := mgr.Register(ps.Name, nil, nil)
+		if len(ps.After) == 1 {
+			srvc.After(ps.After[0])
+		}
+		mgr.placeholders[ps.Name] = true
+	}
+
+	return mgr, nil
+}
+
+// Save writes the name of the boot sequence and the dependency structure of all registered Services to w as a JSON
+// document that can be read by LoadManager. Services are listed alphabetically. Service functions are not saved.
+func (m *Manager) Save(w io.Writer) error {
+	m.lock.Lock()
+	p := plan{Name: m.name, Services: make([]planService, 0, len(m.services))}
+	for name, srvc := range m.services {
+		ps := planService{Name: name, After: []string{}}
+		if srvc.after != "" {
+			ps.After = append(ps.After, srvc.after)
+		}
+		p.Services = append(p.Services, ps)
+	}
+	m.lock.Unlock()
+
+	sort.Slice(p.Services, func(i, j int) bool {
+		return p.Services[i].Name < p.Services[j].Name
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(p)
+}
@@ -0,0 +1,78 @@
+package bootseq
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// runReport is the serialised summary of the most recent run of an Agent.
+type runReport struct {
+	Name     string          `json:"name"`
+	Phase    string          `json:"phase"`
+	Start    time.Time       `json:"start"`
+	End      *time.Time      `json:"end"` // Null while the run is in progress.
+	Services []serviceReport `json:"services"`
+}
+
+// serviceReport is the serialised result of a single Service during a run.
+type serviceReport struct {
+	Name     string `json:"name"`
+	Priority uint16 `json:"priority"`
+	Duration string `json:"duration"`
+	Err      string `json:"error,omitempty"`
+}
+
+// RunJSON returns a JSON document summarising the most recent startup or shutdown sequence, which is useful for
+// attaching to post-mortems. The document contains the name of the boot sequence, the phase, the start and end times
+// of the run, and the result of each Service that ran, ordered by priority and name:
+//
+//	{
+//		"name": "My Boot Sequence",
+//		"phase": "up",
+//		"start": "2020-01-01T12:00:00Z",
+//		"end": "2020-01-01T12:00:01Z",
+//		"services": [
+//			{"name": "db", "priority": 1, "duration": "1s", "error": "connection refused"}
+//		]
+//	}
+//
+// Errors are serialised as their messages, and end is null while the run is still in progress. RunJSON returns an
+// InvalidStateError if no sequence has run yet.
+func (a *Agent) RunJSON() ([]byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.state == stateIdle {
+		return nil, InvalidStateError(idleErrorMessage)
+	}
+
+	r := runReport{Name: a.name, Phase: a.state.String(), Start: a.runStart, Services: []serviceReport{}}
+	if !a.runEnd.IsZero() {
+		end := a.runEnd
+		r.End = &end
+	}
+
+	for priority := range a.orderedServices {
+		for _, name := range a.names(priority) {
+			d, timed := a.timings[name]
+			err, failed := a.failures[name]
+			if !timed && !failed {
+				continue // The Service didn't run.
+			}
+			sr := serviceReport{Name: name, Priority: priority, Duration: d.String()}
+			if failed {
+				sr.Err = err.Error()
+			}
+			r.Services = append(r.Services, sr)
+		}
+	}
+	sort.Slice(r.Services, func(i, j int) bool {
+		if r.Services[i].Priority != r.Services[j].Priority {
+			return r.Services[i].Priority < r.Services[j].Priority
+		}
+		return r.Services[i].Name < r.Services[j].Name
+	})
+
+	return json.Marshal(r)
+}
@@ -0,0 +1,96 @@
+package bootseq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time that Run allows for the shutdown sequence to complete.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Run encapsulates the typical lifecycle of an application: it instantiates an Agent from m, runs the startup
+// sequence, and then blocks until ctx is done, such as when the caller cancels it upon receiving an OS signal (see
+// signal.NotifyContext). Finally, it runs the shutdown sequence with a fresh timeout of DefaultShutdownTimeout, which
+// retains the values of ctx.
+// Run returns any error from Manager.Agent up front. If the startup sequence fails, Run shuts down the Services that
+// came up before it stopped right away, and returns the error of the startup sequence, joined with that of the
+// shutdown sequence, if any; see Agent.Run. Otherwise, it returns the error of the shutdown sequence. progressFn
+// receives the Progress of both sequences, and may be nil.
+func Run(ctx context.Context, m *Manager, progressFn func(Progress)) error {
+	agent, err := m.Agent()
+	if err != nil {
+		return err
+	}
+	return agent.Run(ctx, nil, progressFn, progressFn)
+}
+
+// Run runs the startup sequence, calls onReady, and then blocks until either the channel returned by onReady is
+// closed or receives a value, or ctx is done. Finally, it runs the shutdown sequence with a fresh timeout of
+// DefaultShutdownTimeout, which retains the values of ctx, so the shutdown sequence runs even if ctx was cancelled
+// while waiting. up and down receive the Progress of the startup and shutdown sequences respectively, and may be nil.
+// A nil onReady, or a nil channel, makes Run wait for ctx alone.
+// If the startup sequence fails, or is cancelled, Run skips onReady, and runs the shutdown sequence right away, which
+// shuts down the Services that came up before the startup sequence stopped; see Agent.Down. Run then returns the
+// error of the startup sequence, joined with that of the shutdown sequence, if any. Otherwise, it returns the error of
+// the shutdown sequence. If the Agent's current state doesn't allow the startup sequence to start, Run returns the
+// InvalidStateError from Up without running either sequence.
+func (a *Agent) Run(ctx context.Context, onReady func() <-chan struct{}, up, down func(Progress)) error {
+	err := a.Up(ctx, up)
+	if _, ok := err.(InvalidStateError); ok {
+		return err
+	}
+	if err == nil {
+		var stop <-chan struct{}
+		if onReady != nil {
+			stop = onReady()
+		}
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultShutdownTimeout)
+	defer cancel()
+	derr := a.Down(ctx, down)
+	if err == nil {
+		return derr
+	}
+	if derr != nil {
+		return errors.Join(err, derr)
+	}
+	return err
+}
+
+// RecordRun runs the startup sequence of agent, and returns every Progress that it reported, in the order they were
+// received, along with the error of the sequence. The recorded events can be fed to a progress function with Replay,
+// such as when developing a user interface, without executing any Services.
+func RecordRun(ctx context.Context, agent *Agent) ([]Progress, error) {
+	var (
+		lock   sync.Mutex
+		events []Progress
+	)
+	err := agent.Up(ctx, func(p Progress) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, p)
+	})
+
+	lock.Lock()
+	defer lock.Unlock()
+	return events, err
+}
+
+// Replay calls fn with each of the given events in order, such as those returned by RecordRun, on the calling
+// goroutine. If interval is positive, Replay waits for it between consecutive events, which is useful for paced
+// demos. Replay returns once every event has been replayed.
+func Replay(events []Progress, fn func(Progress), interval time.Duration) {
+	for i, p := range events {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		fn(p)
+	}
+}
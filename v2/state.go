@@ -0,0 +1,89 @@
+package bootseq
+
+import "sync"
+
+// StateFunc is the type used for Service functions that share values through the State of the Agent running them.
+// See Manager.RegisterStateful.
+type StateFunc func(st *State) error
+
+// State is a bag of values kept by an Agent for the duration of both its startup and shutdown sequences. Values are
+// keyed by Service name, which allows an "up" function to pass a value, such as a handle to an open resource, on to
+// the "down" function of the same Service. State is safe for concurrent use.
+type State struct {
+	lock   sync.Mutex
+	values map[string]any
+}
+
+// newState returns a new and empty State.
+func newState() *State {
+	return &State{values: make(map[string]any)}
+}
+
+// Set stores the given value under the given Service name, replacing any value already stored.
+func (s *State) Set(name string, value any) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.values[name] = value
+}
+
+// Get returns the value stored under the given Service name, and whether a value was found.
+func (s *State) Get(name string) (any, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// Delete removes the value stored under the given Service name, if any.
+func (s *State) Delete(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.values, name)
+}
+
+// bind sets the up and down functions of the Service to call its StateFuncs, if any, with the given State.
+func (s *Service) bind(st *State) {
+	if up := s.upState; up != nil {
+		s.up = func() error { return up(st) }
+	}
+	if down := s.downState; down != nil {
+		s.down = func() error { return down(st) }
+	}
+}
+
+// RegisterResource registers a single named Service like Register, for Services that open a resource during startup,
+// such as a database pool, and close it during shutdown. The value returned by "up" is stored by the Agent and passed
+// to "down", which spares the functions from sharing a variable between goroutines. The value is only stored if "up"
+// succeeds, and is available from the State of the Agent under the name of the Service in the meantime.
+func (m *Manager) RegisterResource(name string, up func() (any, error), down func(any) error) *Service {
+	var upState, downState StateFunc
+	if up != nil {
+		upState = func(st *State) error {
+			value, err := up()
+			if err != nil {
+				return err
+			}
+			st.Set(name, value)
+			return nil
+		}
+	}
+	if down != nil {
+		downState = func(st *State) error {
+			value, _ := st.Get(name)
+			if err := down(value); err != nil {
+				return err
+			}
+			st.Delete(name)
+			return nil
+		}
+	}
+	return m.RegisterStateful(name, upState, downState)
+}
+
+// State returns the State shared by the StateFuncs of the Services that the Agent runs.
+func (a *Agent) State() *State {
+	return a.st
+}